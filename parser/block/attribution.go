@@ -9,8 +9,6 @@ import (
 )
 
 var (
-	ErrInvalidDateFormat       = errors.New("invalid date format")
-	ErrInvalidTimeFormat       = errors.New("invalid time format")
 	ErrInvalidNameFormat       = errors.New("invalid name format")
 	ErrInvalidCaptureKind      = errors.New("invalid capture kind")
 	ErrNoMatchingCaptureGroups = errors.New("match has no matching capture groups")
@@ -19,9 +17,7 @@ var (
 const (
 	attributionNameRegexPart       = `(?:[^<>,"\s]|[^<>,"\s][^<>,"]*[^<>,"\s])`
 	attributionEmailRegexPart      = `[^<>@\s]+@[^<>@\s]*`
-	attributionGroupEmailRegexPart = `[^\s@]+@(?:yahoogroups\.com|y?\.{3})`
-	shortMonthRegexPart            = `(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)`
-	shortWeekdayRegexPart          = `(?:Mon|Tue|Wed|Thu|Fri|Sat|Sun)`
+	attributionGroupEmailRegexPart = `[^\s@]+@(?:yahoo(?:groups|groupes|grupos)(?:\.[a-z]{2,3})+|y?\.{3})`
 )
 
 type regexMatcher interface {
@@ -118,147 +114,34 @@ func (f nameFormat) Regex() *regexp.Regexp {
 	}
 }
 
-type dateFormat string
-
-const (
-	dateFormatShort                    = "Short"
-	dateFormatShortWeekday             = "ShortWeekday"
-	dateFormatShortPadded              = "ShortPadded"
-	dateFormatShortPaddedWeekday       = "ShortPaddedWeekday"
-	dateFormatShortYearMonthDay        = "ShortYearMonthDay"
-	dateFormatShortYearMonthDayWeekday = "ShortYearMonthDayWeekday"
-	dateFormatLongDayMonthYear         = "LongDayMonthYear"
-	dateFormatLongMonthDayYear         = "LongMonthDayYear"
-	dateFormatLongDayMonthYearWeekday  = "LongDayMonthYearWeekday"
-	dateFormatLongMonthDayYearWeekday  = "LongMonthDayYearWeekday"
-)
-
-func allDateFormats() []dateFormat {
-	return []dateFormat{
-		dateFormatLongDayMonthYearWeekday,
-		dateFormatLongMonthDayYearWeekday,
-		dateFormatLongDayMonthYear,
-		dateFormatLongMonthDayYear,
-		dateFormatShortYearMonthDayWeekday,
-		dateFormatShortYearMonthDay,
-		dateFormatShortPaddedWeekday,
-		dateFormatShortWeekday,
-		dateFormatShortPadded,
-		dateFormatShort,
-	}
-}
-
-func (f dateFormat) FormatString() string {
-	switch f {
-	case dateFormatShort:
-		return "1/2/06"
-	case dateFormatShortWeekday:
-		return "Mon, 1/2/06"
-	case dateFormatShortPadded:
-		return "01/02/06"
-	case dateFormatShortPaddedWeekday:
-		return "Mon, 01/02/06"
-	case dateFormatShortYearMonthDay:
-		return "2006-01-02"
-	case dateFormatShortYearMonthDayWeekday:
-		return "Mon, 2006-01-02"
-	case dateFormatLongDayMonthYear:
-		return "2 Jan 2006"
-	case dateFormatLongMonthDayYear:
-		return "Jan 2, 2006"
-	case dateFormatLongDayMonthYearWeekday:
-		return "Mon, 2 Jan 2006"
-	case dateFormatLongMonthDayYearWeekday:
-		return "Mon, Jan 2, 2006"
-	default:
-		panic(fmt.Errorf("%w: %s", ErrInvalidDateFormat, f))
-	}
+// dateFormat is a date format in a particular locale. The layout is a layout
+// for `time.Parse` which uses English month names and no weekday, because the
+// matched text is normalized with `Locale.normalizeDate` before it's parsed.
+//
+// For numeric dates where the order of the day and the month is ambiguous, the
+// order is the order conventional in the locale and the swapped layout is the
+// layout with the day and the month swapped.
+type dateFormat struct {
+	Layout        string
+	SwappedLayout string
+	Order         DateOrder
+	regex         *regexp.Regexp
 }
 
 func (f dateFormat) Regex() *regexp.Regexp {
-	switch f {
-	case dateFormatShort:
-		return regexp.MustCompile(`(\d{1,2}/\d{1,2}/\d{2})`)
-	case dateFormatShortWeekday:
-		return regexp.MustCompile(fmt.Sprintf(`(%s, \d{1,2}/\d{1,2}/\d{2})`, shortWeekdayRegexPart))
-	case dateFormatShortPadded:
-		return regexp.MustCompile(`(\d{2}/\d{2}/\d{2})`)
-	case dateFormatShortPaddedWeekday:
-		return regexp.MustCompile(fmt.Sprintf(`(%s, \d{2}/\d{2}/\d{2})`, shortWeekdayRegexPart))
-	case dateFormatShortYearMonthDay:
-		return regexp.MustCompile(`(\d{4}-\d{2}-\d{2})`)
-	case dateFormatShortYearMonthDayWeekday:
-		return regexp.MustCompile(fmt.Sprintf(`(%s, \d{4}-\d{2}-\d{2})`, shortWeekdayRegexPart))
-	case dateFormatLongDayMonthYear:
-		return regexp.MustCompile(fmt.Sprintf(`(\d{1,2} %s \d{4})`, shortMonthRegexPart))
-	case dateFormatLongMonthDayYear:
-		return regexp.MustCompile(fmt.Sprintf(`(%s \d{1,2}, \d{4})`, shortMonthRegexPart))
-	case dateFormatLongDayMonthYearWeekday:
-		return regexp.MustCompile(fmt.Sprintf(`(%s, \d{1,2} %s \d{4})`, shortWeekdayRegexPart, shortMonthRegexPart))
-	case dateFormatLongMonthDayYearWeekday:
-		return regexp.MustCompile(fmt.Sprintf(`(%s, %s \d{1,2}, \d{4})`, shortWeekdayRegexPart, shortMonthRegexPart))
-	default:
-		panic(fmt.Errorf("%w: %s", ErrInvalidDateFormat, f))
-	}
+	return f.regex
 }
 
-type timeFormat string
-
-const (
-	timeFormatShort12Hr  = "Short12Hr"
-	timeFormatShort24Hr  = "Short24Hr"
-	timeFormatLong       = "Long"
-	timeFormatLongTzName = "LongTzName"
-)
-
-func allTimeFormats() []timeFormat {
-	return []timeFormat{
-		timeFormatLongTzName,
-		timeFormatLong,
-		timeFormatShort12Hr,
-		timeFormatShort24Hr,
-	}
-}
-
-func (f timeFormat) FormatString() string {
-	switch f {
-	case timeFormatShort12Hr:
-		return "3:04 PM"
-	case timeFormatShort24Hr:
-		return "15:04"
-	case timeFormatLong:
-		return "15:04:05 -0700"
-	case timeFormatLongTzName:
-		return "15:04:05 -0700 (MST)"
-	default:
-		panic(fmt.Errorf("%w: %s", ErrInvalidTimeFormat, f))
-	}
+// timeFormat is a time format in a particular locale. The layout is a layout
+// for `time.Parse`.
+type timeFormat struct {
+	Layout      string
+	HasTimeZone bool
+	regex       *regexp.Regexp
 }
 
 func (f timeFormat) Regex() *regexp.Regexp {
-	switch f {
-	case timeFormatShort12Hr:
-		return regexp.MustCompile(`(\d{1,2}:\d{2} (?:AM|PM))`)
-	case timeFormatShort24Hr:
-		return regexp.MustCompile(`(\d{1,2}:\d{2})`)
-	case timeFormatLong:
-		return regexp.MustCompile(`(\d{2}:\d{2}:\d{2} [+-]\d{4})`)
-	case timeFormatLongTzName:
-		return regexp.MustCompile(`(\d{2}:\d{2}:\d{2} [+-]\d{4} \([A-Z]{2,5}\))`)
-	default:
-		panic(fmt.Errorf("%w: %s", ErrInvalidTimeFormat, f))
-	}
-}
-
-func (f timeFormat) HasTimeZone() bool {
-	switch f {
-	case timeFormatShort12Hr, timeFormatShort24Hr:
-		return false
-	case timeFormatLong, timeFormatLongTzName:
-		return true
-	default:
-		panic(fmt.Errorf("%w: %s", ErrInvalidTimeFormat, f))
-	}
+	return f.regex
 }
 
 type attributionRegexPart interface {
@@ -395,74 +278,49 @@ func (r *attributionRegex) TimeIndices(match []int) (start, end int, format time
 	return start, end, matcher.(timeFormat)
 }
 
-var attributionRegexes = []attributionRegex{
-	{
-		Template: `(?m)^%[1]s(?:-{2,3}\s+)?On\s+%[2]s\s+(?:at\s+)?%[3]s,?\s+%[4]s\s+wrote:\s+`,
-		Parts: []attributionRegexPart{
-			attributionRegexLiteral(nonNewlineWhitespaceRegexPart),
-			attributionRegexCaptureDate,
-			attributionRegexCaptureTime,
-			attributionRegexCaptureName,
-		},
-		NameFormats: allNameFormats(),
-		DateFormats: allDateFormats(),
-		TimeFormats: allTimeFormats(),
-	},
-	{
-		Template: `(?m)^%[1]s(?:-{2,3}\s+)?On\s+%[2]s,?\s+%[3]s\s+wrote:\s+`,
-		Parts: []attributionRegexPart{
-			attributionRegexLiteral(nonNewlineWhitespaceRegexPart),
-			attributionRegexCaptureDate,
-			attributionRegexCaptureName,
-		},
-		NameFormats: allNameFormats(),
-		DateFormats: allDateFormats(),
-		TimeFormats: nil,
-	},
-	{
-		Template: `(?m)^%[1]s(?:-{2,3}\s+)?In\s+%[2]s,\s+%[3]s\s+wrote:\s+`,
-		Parts: []attributionRegexPart{
-			attributionRegexLiteral(nonNewlineWhitespaceRegexPart),
-			attributionRegexLiteral(attributionGroupEmailRegexPart),
-			attributionRegexCaptureName,
-		},
-		NameFormats: allNameFormats(),
-		DateFormats: nil,
-		TimeFormats: nil,
-	},
-	{
-		Template: `(?m)^%[1]s-{2,3}\s+%[2]s\s+wrote:\s+`,
-		Parts: []attributionRegexPart{
-			attributionRegexLiteral(nonNewlineWhitespaceRegexPart),
-			attributionRegexCaptureName,
-		},
-		NameFormats: allNameFormats(),
-		DateFormats: nil,
-		TimeFormats: nil,
-	},
-	{
-		Template: `(?m)^%[1]s%[2]s%[1]swrote:\s+`,
-		Parts: []attributionRegexPart{
-			attributionRegexLiteral(nonNewlineWhitespaceRegexPart),
-			attributionRegexCaptureName,
-		},
-		// We only allow name formats that include an email address to reduce
-		// the likelihood of false positive matches on this pattern.
-		NameFormats: allEmailNameFormats(),
-		DateFormats: nil,
-		TimeFormats: nil,
-	},
-}
-
 type AttributionBlock struct {
 	Name    string
 	Time    time.Time
 	HasTime bool
+
+	// The date as it was written in the attribution line.
+	DateText string
+
+	// Whether the order of the day and the month in the date couldn't be
+	// determined, in which case `Time` is only a best guess.
+	DateAmbiguous bool
+
+	// Whether the time zone of `Time` is known. Attribution lines usually don't
+	// include a time zone, in which case `Time` is in UTC until it's resolved
+	// from the other messages in the archive with `SetTimeZone`.
+	HasTimeZone bool
+
+	// Whether the time zone of `Time` was guessed from the time zone the
+	// quoted person usually uses rather than found from the quoted message.
+	IsGuessedZone bool
+
+	// The URL of the profile page of the person who wrote the quoted message.
+	// This is set when the name matches someone who posted in the archive.
+	NameHref string
+
+	QuoteLink
+
+	config  Config
+	pattern string
 }
 
-func (b *AttributionBlock) FromText(text string) (ok bool, before, after string) {
-	for i := range attributionRegexes {
-		regex := &attributionRegexes[i]
+// combineDateAndTime returns the time of day of `timeOfDay`, in its time zone,
+// on the date of `date`, which was parsed without a time zone.
+func combineDateAndTime(date, timeOfDay time.Time) time.Time {
+	hour, minute, second := timeOfDay.Clock()
+	sinceMidnight := time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute + time.Duration(second)*time.Second + time.Duration(timeOfDay.Nanosecond())
+
+	return WallClockIn(date.Add(sinceMidnight), timeOfDay.Location())
+}
+
+func (b *AttributionBlock) fromTextInLocale(text string, locale *Locale) (ok bool, before, after string) {
+	for i := range locale.attributionRegexes {
+		regex := &locale.attributionRegexes[i]
 
 		match := regex.Regex().FindStringSubmatchIndex(text)
 		if match == nil {
@@ -476,9 +334,16 @@ func (b *AttributionBlock) FromText(text string) (ok bool, before, after string)
 
 		var err error
 
+		b.Time = time.Time{}
+		b.DateText = ""
+		b.DateAmbiguous = false
+		b.HasTimeZone = false
+		b.IsGuessedZone = false
+
 		if regex.HasDate() {
 			dateStartIndex, dateEndIndex, matchedDateFormat := regex.DateIndices(match)
-			b.Time, err = time.Parse(matchedDateFormat.FormatString(), text[dateStartIndex:dateEndIndex])
+			b.DateText = text[dateStartIndex:dateEndIndex]
+			b.Time, b.DateAmbiguous, err = parseDate(locale.normalizeDate(b.DateText), matchedDateFormat, b.config)
 			if err != nil {
 				continue
 			}
@@ -486,7 +351,7 @@ func (b *AttributionBlock) FromText(text string) (ok bool, before, after string)
 
 		if regex.HasTime() {
 			timeStartIndex, timeEndIndex, matchedTimeFormat := regex.TimeIndices(match)
-			localTime, err := time.Parse(matchedTimeFormat.FormatString(), text[timeStartIndex:timeEndIndex])
+			localTime, err := time.Parse(matchedTimeFormat.Layout, text[timeStartIndex:timeEndIndex])
 			if err != nil {
 				continue
 			}
@@ -494,14 +359,51 @@ func (b *AttributionBlock) FromText(text string) (ok bool, before, after string)
 			if b.Time.IsZero() {
 				b.Time = localTime
 			} else {
-				b.Time = b.Time.Add(localTime.Sub(time.Time{}))
+				b.Time = combineDateAndTime(b.Time, localTime)
 			}
+
+			b.HasTimeZone = matchedTimeFormat.HasTimeZone
 		}
 
 		b.HasTime = regex.HasTime()
+		b.pattern = fmt.Sprintf("%s attribution %d", locale.Name, i+1)
 
 		return true, text[:matchStartIndex], text[matchEndIndex:]
 	}
 
 	return false, "", ""
 }
+
+func (b *AttributionBlock) MatchedPattern() string {
+	return b.pattern
+}
+
+// SetTimeZone sets the time zone of a time which was parsed without one,
+// keeping the same wall clock time.
+func (b *AttributionBlock) SetTimeZone(location *time.Location) {
+	b.Time = WallClockIn(b.Time, location)
+	b.HasTimeZone = true
+	b.IsGuessedZone = false
+}
+
+// GuessTimeZone is like `SetTimeZone`, but for a time zone which is only a
+// guess.
+func (b *AttributionBlock) GuessTimeZone(location *time.Location) {
+	b.SetTimeZone(location)
+	b.IsGuessedZone = true
+}
+
+func (b *AttributionBlock) FromText(text string) (ok bool, before, after string) {
+	locales := b.config.Locales
+	if len(locales) == 0 {
+		locales = []*Locale{DefaultLocale()}
+	}
+
+	for _, locale := range locales {
+		if ok, before, after := b.fromTextInLocale(text, locale); ok {
+			return true, before, after
+		}
+	}
+
+	return false, "", ""
+}
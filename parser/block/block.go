@@ -1,17 +1,79 @@
 package block
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 const nonNewlineWhitespaceRegexPart = `[\t ]*`
 
 type Block interface {
 	ToHtml() string
+	ToMarkdown() string
 	FromText(text string) (ok bool, before, after string)
 }
 
+// PatternBlock is a block which can be matched by more than one pattern.
+type PatternBlock interface {
+	Block
+
+	// MatchedPattern returns a name for the pattern which matched the block
+	// the last time `FromText` succeeded.
+	MatchedPattern() string
+}
+
+// Config determines how blocks are parsed.
+type Config struct {
+	// The locales to recognize attribution lines in, in the order they're
+	// tried. If this is empty, only the default locale is used.
+	Locales []*Locale
+
+	// The order of the day and the month to assume in numeric dates when it
+	// can't be determined from the context.
+	DateOrder DateOrder
+
+	// The date of the message being parsed, which is used to disambiguate
+	// dates in attribution lines.
+	ReferenceTime time.Time
+
+	// User-defined blocks, which are tried before or after the built-in
+	// blocks depending on their priority.
+	CustomBlocks []*CustomBlockDefinition
+}
+
 func AllBlocks() []Block {
-	return []Block{
+	return ConfiguredBlocks(Config{})
+}
+
+// ConfiguredBlocks returns every block, configured with `config`.
+func ConfiguredBlocks(config Config) []Block {
+	builtins := []Block{
 		&HardBreakBlock{},
 		&DividerBlock{},
+		&ForwardedBlock{},
 		&MessageHeaderBlock{},
-		&AttributionBlock{},
+		&AttributionBlock{config: config},
+	}
+
+	return withCustomBlocks(builtins, config.CustomBlocks)
+}
+
+// BlockName returns a name for the kind of a block, for debugging.
+func BlockName(b Block) string {
+	if custom, isCustom := b.(*CustomBlock); isCustom {
+		return "CustomBlock(" + custom.Name() + ")"
 	}
+
+	return strings.TrimPrefix(fmt.Sprintf("%T", b), "*block.")
+}
+
+// MatchedPattern returns a name for the pattern which matched a block, for
+// debugging, or an empty string if there's only one pattern for the block.
+func MatchedPattern(b Block) string {
+	if patternBlock, hasPatterns := b.(PatternBlock); hasPatterns {
+		return patternBlock.MatchedPattern()
+	}
+
+	return ""
 }
@@ -0,0 +1,211 @@
+package block
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/sprig/v3"
+	"github.com/acearchive/yahoo-groups-reader/logger"
+	"html"
+	"html/template"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidCustomBlock = errors.New("invalid custom block")
+
+// builtinBlockPriority is the priority of the built-in blocks. Custom blocks
+// with a higher priority are tried before the built-in blocks, and custom
+// blocks with the same or a lower priority are tried after them.
+const builtinBlockPriority = 0
+
+// CustomBlockSpec is a user-defined block as it's written in a config file.
+type CustomBlockSpec struct {
+	// A unique name for the block.
+	Name string `json:"name"`
+
+	// A regular expression which matches the block. Named capture groups are
+	// passed to the template.
+	Pattern string `json:"pattern"`
+
+	// Blocks with a higher priority are tried first.
+	Priority int `json:"priority"`
+
+	// An `html/template` snippet which renders the block. It's passed the
+	// `.Name` of the block, the matched `.Text`, and the named `.Captures`.
+	Template string `json:"template"`
+}
+
+// CustomBlockDefinition is a compiled user-defined block.
+type CustomBlockDefinition struct {
+	Name     string
+	Priority int
+	regex    *regexp.Regexp
+	template *template.Template
+}
+
+func NewCustomBlockDefinition(spec CustomBlockSpec) (*CustomBlockDefinition, error) {
+	if spec.Name == "" {
+		return nil, fmt.Errorf("%w: missing name", ErrInvalidCustomBlock)
+	}
+
+	if spec.Pattern == "" {
+		return nil, fmt.Errorf("%w: '%s': missing pattern", ErrInvalidCustomBlock, spec.Name)
+	}
+
+	regex, err := regexp.Compile(spec.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: '%s': %v", ErrInvalidCustomBlock, spec.Name, err)
+	}
+
+	if regex.MatchString("") {
+		return nil, fmt.Errorf("%w: '%s': pattern matches the empty string", ErrInvalidCustomBlock, spec.Name)
+	}
+
+	blockTemplate, err := template.New(spec.Name).Funcs(sprig.FuncMap()).Parse(spec.Template)
+	if err != nil {
+		return nil, fmt.Errorf("%w: '%s': %v", ErrInvalidCustomBlock, spec.Name, err)
+	}
+
+	return &CustomBlockDefinition{
+		Name:     spec.Name,
+		Priority: spec.Priority,
+		regex:    regex,
+		template: blockTemplate,
+	}, nil
+}
+
+// LoadCustomBlocks loads user-defined blocks from a JSON file containing an
+// array of block specs.
+func LoadCustomBlocks(path string) ([]*CustomBlockDefinition, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+
+	var specs []CustomBlockSpec
+
+	if err := decoder.Decode(&specs); err != nil {
+		return nil, fmt.Errorf("%w: '%s': %v", ErrInvalidCustomBlock, path, err)
+	}
+
+	definitions := make([]*CustomBlockDefinition, len(specs))
+	names := make(map[string]struct{}, len(specs))
+
+	for i, spec := range specs {
+		if _, isDuplicate := names[spec.Name]; isDuplicate {
+			return nil, fmt.Errorf("%w: '%s': duplicate name", ErrInvalidCustomBlock, spec.Name)
+		}
+
+		names[spec.Name] = struct{}{}
+
+		if definitions[i], err = NewCustomBlockDefinition(spec); err != nil {
+			return nil, err
+		}
+	}
+
+	return definitions, nil
+}
+
+// NewBlock returns a new block for this definition.
+func (d *CustomBlockDefinition) NewBlock() *CustomBlock {
+	return &CustomBlock{definition: d}
+}
+
+// CustomBlock is a block defined by the user in a config file.
+type CustomBlock struct {
+	Text       string
+	Captures   map[string]string
+	definition *CustomBlockDefinition
+}
+
+type customBlockTemplateParams struct {
+	Name     string
+	Text     string
+	Captures map[string]string
+}
+
+func (b *CustomBlock) Name() string {
+	return b.definition.Name
+}
+
+func (b *CustomBlock) FromText(text string) (ok bool, before, after string) {
+	match := b.definition.regex.FindStringSubmatchIndex(text)
+	if match == nil || match[0] == match[1] {
+		return false, "", ""
+	}
+
+	b.Text = text[match[0]:match[1]]
+	b.Captures = make(map[string]string)
+
+	for i, name := range b.definition.regex.SubexpNames() {
+		if name != "" && match[2*i] >= 0 {
+			b.Captures[name] = text[match[2*i]:match[2*i+1]]
+		}
+	}
+
+	return true, text[:match[0]], text[match[1]:]
+}
+
+func (b *CustomBlock) ToHtml() string {
+	params := customBlockTemplateParams{
+		Name:     b.definition.Name,
+		Text:     b.Text,
+		Captures: b.Captures,
+	}
+
+	var output strings.Builder
+
+	// Unlike the built-in templates, a custom template can fail at runtime
+	// because of how it was written, so we fall back to the plain text.
+	if err := b.definition.template.Execute(&output, params); err != nil {
+		logger.Verbose.Printf("%v: '%s': %v", ErrInvalidCustomBlock, b.definition.Name, err)
+		return fmt.Sprintf("<p>%s</p>", html.EscapeString(strings.TrimSpace(b.Text)))
+	}
+
+	return strings.TrimSpace(output.String())
+}
+
+func (b *CustomBlock) ToMarkdown() string {
+	return EscapeMarkdown(strings.TrimSpace(b.Text))
+}
+
+// withCustomBlocks returns `builtins` with new blocks for `definitions`
+// inserted according to their priority.
+func withCustomBlocks(builtins []Block, definitions []*CustomBlockDefinition) []Block {
+	if len(definitions) == 0 {
+		return builtins
+	}
+
+	sorted := make([]*CustomBlockDefinition, len(definitions))
+	copy(sorted, definitions)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority > sorted[j].Priority
+	})
+
+	blocks := make([]Block, 0, len(builtins)+len(sorted))
+
+	for _, definition := range sorted {
+		if definition.Priority > builtinBlockPriority {
+			blocks = append(blocks, definition.NewBlock())
+		}
+	}
+
+	blocks = append(blocks, builtins...)
+
+	for _, definition := range sorted {
+		if definition.Priority <= builtinBlockPriority {
+			blocks = append(blocks, definition.NewBlock())
+		}
+	}
+
+	return blocks
+}
@@ -0,0 +1,129 @@
+package block
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidDateOrder = errors.New("invalid date order")
+
+// DateOrder is the order of the day and the month in numeric dates like
+// "03/04/02".
+type DateOrder string
+
+const (
+	// DateOrderLocale uses the order that's conventional in the locale the
+	// attribution line is written in.
+	DateOrderLocale     DateOrder = ""
+	DateOrderMonthFirst DateOrder = "mdy"
+	DateOrderDayFirst   DateOrder = "dmy"
+)
+
+func ParseDateOrder(input string) (DateOrder, error) {
+	switch order := DateOrder(input); order {
+	case DateOrderLocale, DateOrderMonthFirst, DateOrderDayFirst:
+		return order, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrInvalidDateOrder, input)
+	}
+}
+
+const (
+	// How far after the date of the message an attribution date can be and
+	// still be a plausible date for the message it quotes, to allow for clock
+	// skew between senders.
+	attributionDateMaxSkew = 24 * time.Hour
+
+	// How many times further from the date of the message one interpretation of
+	// an attribution date needs to be than the other for us to be confident the
+	// nearer one is right.
+	attributionDateMinRatio = 3
+)
+
+func absDuration(duration time.Duration) time.Duration {
+	if duration < 0 {
+		return -duration
+	}
+
+	return duration
+}
+
+// WallClockIn returns the instant at which the wall clock in `location` reads
+// the same as `wallClock`.
+func WallClockIn(wallClock time.Time, location *time.Location) time.Time {
+	return time.Date(
+		wallClock.Year(), wallClock.Month(), wallClock.Day(),
+		wallClock.Hour(), wallClock.Minute(), wallClock.Second(), wallClock.Nanosecond(),
+		location,
+	)
+}
+
+// isPlausible returns whether `date` is a plausible date for a message quoted
+// by a message sent at `reference`.
+func isPlausible(date, reference time.Time) bool {
+	return !date.After(reference.Add(attributionDateMaxSkew))
+}
+
+// chooseDate picks between two interpretations of a numeric date, where
+// `asWritten` is the interpretation using the order conventional in the locale
+// and `swapped` is the interpretation with the day and month swapped. Of the
+// plausible interpretations, it picks the one nearest to `reference`, and
+// returns whether the choice is ambiguous because both are about as near.
+func chooseDate(asWritten, swapped time.Time, order, defaultOrder DateOrder, reference time.Time) (date time.Time, ambiguous bool) {
+	byConfiguredOrder := asWritten
+	if defaultOrder != DateOrderLocale && defaultOrder != order {
+		byConfiguredOrder = swapped
+	}
+
+	if reference.IsZero() {
+		return byConfiguredOrder, true
+	}
+
+	asWrittenPlausible, swappedPlausible := isPlausible(asWritten, reference), isPlausible(swapped, reference)
+
+	switch {
+	case asWrittenPlausible && !swappedPlausible:
+		return asWritten, false
+	case swappedPlausible && !asWrittenPlausible:
+		return swapped, false
+	case !asWrittenPlausible && !swappedPlausible:
+		return byConfiguredOrder, true
+	}
+
+	asWrittenDistance, swappedDistance := absDuration(reference.Sub(asWritten)), absDuration(reference.Sub(swapped))
+
+	nearest, nearestDistance, furthestDistance := asWritten, asWrittenDistance, swappedDistance
+	if swappedDistance < asWrittenDistance {
+		nearest, nearestDistance, furthestDistance = swapped, swappedDistance, asWrittenDistance
+	}
+
+	return nearest, furthestDistance < nearestDistance*attributionDateMinRatio
+}
+
+// parseDate parses a normalized date in the given format. When the order of
+// the day and the month is ambiguous, this uses the date of the enclosing
+// message and the configured date order to pick an interpretation, and
+// returns whether it's still ambiguous.
+func parseDate(text string, format dateFormat, config Config) (date time.Time, ambiguous bool, err error) {
+	asWritten, asWrittenErr := time.Parse(format.Layout, text)
+
+	if format.SwappedLayout == "" {
+		return asWritten, false, asWrittenErr
+	}
+
+	swapped, swappedErr := time.Parse(format.SwappedLayout, text)
+
+	switch {
+	case asWrittenErr != nil && swappedErr != nil:
+		return time.Time{}, false, asWrittenErr
+	case asWrittenErr != nil:
+		return swapped, false, nil
+	case swappedErr != nil || asWritten.Equal(swapped):
+		return asWritten, false, nil
+	}
+
+	date, ambiguous = chooseDate(asWritten, swapped, format.Order, config.DateOrder, config.ReferenceTime)
+
+	return date, ambiguous, nil
+}
@@ -0,0 +1,112 @@
+package block
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChooseDate(t *testing.T) {
+	day := func(month time.Month, day int) time.Time {
+		return time.Date(2003, month, day, 12, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name          string
+		asWritten     time.Time
+		swapped       time.Time
+		order         DateOrder
+		defaultOrder  DateOrder
+		reference     time.Time
+		wantDate      time.Time
+		wantAmbiguous bool
+	}{
+		{
+			name:          "no reference uses the locale order",
+			asWritten:     day(time.March, 4),
+			swapped:       day(time.April, 3),
+			order:         DateOrderMonthFirst,
+			defaultOrder:  DateOrderLocale,
+			wantDate:      day(time.March, 4),
+			wantAmbiguous: true,
+		},
+		{
+			name:          "no reference uses the configured order",
+			asWritten:     day(time.March, 4),
+			swapped:       day(time.April, 3),
+			order:         DateOrderMonthFirst,
+			defaultOrder:  DateOrderDayFirst,
+			wantDate:      day(time.April, 3),
+			wantAmbiguous: true,
+		},
+		{
+			name:         "only the reading as written is before the reference",
+			asWritten:    day(time.March, 4),
+			swapped:      day(time.April, 3),
+			order:        DateOrderMonthFirst,
+			defaultOrder: DateOrderDayFirst,
+			reference:    day(time.March, 10),
+			wantDate:     day(time.March, 4),
+		},
+		{
+			name:         "only the swapped reading is before the reference",
+			asWritten:    day(time.April, 3),
+			swapped:      day(time.March, 4),
+			order:        DateOrderMonthFirst,
+			defaultOrder: DateOrderLocale,
+			reference:    day(time.March, 10),
+			wantDate:     day(time.March, 4),
+		},
+		{
+			name:          "neither reading is before the reference",
+			asWritten:     day(time.March, 4),
+			swapped:       day(time.April, 3),
+			order:         DateOrderMonthFirst,
+			defaultOrder:  DateOrderLocale,
+			reference:     day(time.February, 1),
+			wantDate:      day(time.March, 4),
+			wantAmbiguous: true,
+		},
+		{
+			name:         "the nearest reading is much nearer",
+			asWritten:    day(time.January, 2),
+			swapped:      day(time.February, 1),
+			order:        DateOrderMonthFirst,
+			defaultOrder: DateOrderLocale,
+			reference:    day(time.February, 2),
+			wantDate:     day(time.February, 1),
+		},
+		{
+			name:          "both readings are about as near",
+			asWritten:     day(time.January, 2),
+			swapped:       day(time.February, 1),
+			order:         DateOrderMonthFirst,
+			defaultOrder:  DateOrderLocale,
+			reference:     day(time.March, 1),
+			wantDate:      day(time.February, 1),
+			wantAmbiguous: true,
+		},
+		{
+			name:         "a reading less than a day after the reference is plausible",
+			asWritten:    day(time.March, 4).Add(6 * time.Hour),
+			swapped:      day(time.April, 3),
+			order:        DateOrderMonthFirst,
+			defaultOrder: DateOrderLocale,
+			reference:    day(time.March, 4),
+			wantDate:     day(time.March, 4).Add(6 * time.Hour),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			date, ambiguous := chooseDate(test.asWritten, test.swapped, test.order, test.defaultOrder, test.reference)
+
+			if !date.Equal(test.wantDate) {
+				t.Errorf("got %v, want %v", date, test.wantDate)
+			}
+
+			if ambiguous != test.wantAmbiguous {
+				t.Errorf("got ambiguous %v, want %v", ambiguous, test.wantAmbiguous)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package block
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const forwardedFieldNameRegexPart = `From|Reply-To|To|Cc|Subject|Date|Sent`
+
+// forwardedBanner is a line which introduces a forwarded message. If `Regex`
+// has a capture group, it captures the name of whoever forwarded the message.
+type forwardedBanner struct {
+	Label string
+	Regex string
+}
+
+var forwardedBanners = []forwardedBanner{
+	{Label: "Forwarded message", Regex: `-{2,} ?Forwarded [Mm]essage ?-{2,}`},
+	{Label: "Forwarded", Regex: `-{2,} ?Forwarded by ([^\n]*?) ?-{2,}`},
+	{Label: "Begin forwarded message", Regex: `Begin forwarded message:`},
+	{Label: "Original message", Regex: `-+ ?Original Message ?-+`},
+}
+
+var (
+	forwardedBannerRegexes = func() []*regexp.Regexp {
+		regexes := make([]*regexp.Regexp, len(forwardedBanners))
+
+		for i, banner := range forwardedBanners {
+			regexes[i] = regexp.MustCompile(fmt.Sprintf(`(?m)^%[1]s%[2]s%[1]s(?:\n|$)`, nonNewlineWhitespaceRegexPart, banner.Regex))
+		}
+
+		return regexes
+	}()
+	forwardedFieldRegex = regexp.MustCompile(fmt.Sprintf(`^%s(%s): +(\S[^\n]*)(?:\n|$)`, nonNewlineWhitespaceRegexPart, forwardedFieldNameRegexPart))
+)
+
+// ForwardedBlock is the banner and header list which introduce a forwarded
+// message. The body of the forwarded message is everything after the block up
+// to the end of the enclosing quote or message, and it's up to the tokenizer
+// to nest that content inside the block.
+type ForwardedBlock struct {
+	Label  string
+	Fields []Field
+	QuoteLink
+
+	pattern   string
+	hasHeader bool
+}
+
+func parseForwardedFields(text string) (fields []Field, endIndex int) {
+	for {
+		match := forwardedFieldRegex.FindStringSubmatchIndex(text[endIndex:])
+		if match == nil {
+			return fields, endIndex
+		}
+
+		fields = append(fields, Field{
+			Name:  text[endIndex+match[2] : endIndex+match[3]],
+			Value: strings.TrimSpace(text[endIndex+match[4] : endIndex+match[5]]),
+		})
+
+		endIndex += match[1]
+	}
+}
+
+func (b *ForwardedBlock) FromText(text string) (ok bool, before, after string) {
+	for i, regex := range forwardedBannerRegexes {
+		match := regex.FindStringSubmatchIndex(text)
+		if match == nil {
+			continue
+		}
+
+		matchStartIndex, matchEndIndex := match[0], match[1]
+
+		headerFields, fieldsEndIndex := parseForwardedFields(text[matchEndIndex:])
+
+		var fields []Field
+
+		if len(match) > 2 && match[2] >= 0 {
+			if forwarder := strings.TrimSpace(text[match[2]:match[3]]); forwarder != "" {
+				fields = append(fields, Field{Name: "Forwarded by", Value: forwarder})
+			}
+		}
+
+		b.Label = forwardedBanners[i].Label
+		b.Fields = append(fields, headerFields...)
+		b.pattern = forwardedBanners[i].Regex
+		b.hasHeader = len(headerFields) > 0
+
+		return true, text[:matchStartIndex], text[matchEndIndex+fieldsEndIndex:]
+	}
+
+	return false, "", ""
+}
+
+func (b *ForwardedBlock) MatchedPattern() string {
+	return b.pattern
+}
+
+// AbsorbHeader adds the fields of a message header which directly follows the
+// banner of this block, for when the banner and the header list are separated
+// by a blank line. This returns false if the block already has a header list.
+func (b *ForwardedBlock) AbsorbHeader(header *MessageHeaderBlock) bool {
+	if b.hasHeader {
+		return false
+	}
+
+	b.Fields = append(b.Fields, header.Fields...)
+	b.hasHeader = true
+
+	return true
+}
@@ -20,7 +20,10 @@ type Field struct {
 	Value string
 }
 
-type MessageHeaderBlock []Field
+type MessageHeaderBlock struct {
+	Fields []Field
+	QuoteLink
+}
 
 type messageHeaderFieldPosition struct {
 	LabelStartIndex int
@@ -95,7 +98,7 @@ func (b *MessageHeaderBlock) FromText(text string) (ok bool, before, after strin
 		nextField.Name = strings.TrimSpace(nextField.Name)
 		nextField.Value = strings.TrimSpace(nextField.Value)
 
-		*b = append(*b, nextField)
+		b.Fields = append(b.Fields, nextField)
 	}
 
 	return true, before, after
@@ -0,0 +1,38 @@
+package block
+
+import "strings"
+
+// QuoteLink links a block to the message in the archive that it quotes.
+type QuoteLink struct {
+	// The ID of the quoted message, or empty if it isn't in the archive.
+	MessageID string
+
+	// The URL of the quoted message. This is set when the page the quoted
+	// message is on is known.
+	Href string
+}
+
+func (l *QuoteLink) Link() *QuoteLink {
+	return l
+}
+
+// LinkableBlock is a block which quotes another message, and can be linked to
+// that message if it's in the archive.
+type LinkableBlock interface {
+	Block
+	Link() *QuoteLink
+}
+
+// FieldValue returns the value of the first field with any of the given names,
+// compared case-insensitively.
+func FieldValue(fields []Field, names ...string) (string, bool) {
+	for _, field := range fields {
+		for _, name := range names {
+			if strings.EqualFold(field.Name, name) {
+				return field.Value, true
+			}
+		}
+	}
+
+	return "", false
+}
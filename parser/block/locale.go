@@ -0,0 +1,649 @@
+package block
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrUnknownLocale = errors.New("unknown locale")
+
+const (
+	LocaleEnglish    = "en"
+	LocaleGerman     = "de"
+	LocaleFrench     = "fr"
+	LocaleSpanish    = "es"
+	LocaleDutch      = "nl"
+	LocalePortuguese = "pt"
+	LocaleJapanese   = "ja"
+)
+
+// The placeholder in date format patterns for the month names in the locale.
+const monthPlaceholder = "%[1]s"
+
+type dateFormatSpec struct {
+	Layout string
+
+	// The pattern can use `%[1]s` for the month names in the locale.
+	Pattern string
+
+	// For numeric dates, the order of the day and the month in the layout and
+	// the layout with them swapped.
+	Order         DateOrder
+	SwappedLayout string
+}
+
+type timeFormatSpec struct {
+	Layout      string
+	Pattern     string
+	HasTimeZone bool
+}
+
+type localeSpec struct {
+	Name string
+
+	// The names of the months, starting with January. Each month can have more
+	// than one name, such as abbreviations, and they're matched
+	// case-insensitively.
+	Months [12][]string
+
+	// The names of the weekdays, starting with Sunday. Each weekday can have
+	// more than one name, and they're matched case-insensitively.
+	Weekdays [7][]string
+
+	DateFormats []dateFormatSpec
+	TimeFormats []timeFormatSpec
+
+	// The date and time formats of each attribution regex are filled in from
+	// the locale.
+	Attributions []attributionRegex
+}
+
+// Locale is a set of attribution patterns, month and weekday names, and date
+// and time formats for a particular language.
+type Locale struct {
+	Name string
+
+	monthNames         map[string]time.Month
+	monthRegex         *regexp.Regexp
+	weekdayPrefixRegex *regexp.Regexp
+	weekdaySuffixRegex *regexp.Regexp
+	attributionRegexes []attributionRegex
+}
+
+// alternation returns a case-insensitive regex which matches any of the given
+// names. Longer names are listed first so that abbreviations don't match a
+// prefix of a full name.
+func alternation(names []string) string {
+	sorted := make([]string, len(names))
+	copy(sorted, names)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+
+	quoted := make([]string, len(sorted))
+
+	for i, name := range sorted {
+		quoted[i] = regexp.QuoteMeta(name)
+	}
+
+	return fmt.Sprintf(`(?i:%s)`, strings.Join(quoted, "|"))
+}
+
+func flattenNames(names [][]string) []string {
+	var flattened []string
+
+	for _, group := range names {
+		flattened = append(flattened, group...)
+	}
+
+	return flattened
+}
+
+func newLocale(spec localeSpec) *Locale {
+	locale := &Locale{
+		Name:       spec.Name,
+		monthNames: make(map[string]time.Month),
+	}
+
+	monthNames := flattenNames(spec.Months[:])
+	weekdayNames := flattenNames(spec.Weekdays[:])
+
+	for monthIndex, names := range spec.Months {
+		for _, name := range names {
+			locale.monthNames[strings.ToLower(name)] = time.Month(monthIndex + 1)
+		}
+	}
+
+	monthRegexPart := `(?:)`
+	if len(monthNames) > 0 {
+		monthRegexPart = alternation(monthNames) + `\.?`
+		locale.monthRegex = regexp.MustCompile(fmt.Sprintf(`(%s)\.?`, alternation(monthNames)))
+	}
+
+	weekdayPrefixRegexPart := ""
+	weekdaySuffixRegexPart := ""
+
+	if len(weekdayNames) > 0 {
+		weekdayPrefixRegexPart = fmt.Sprintf(`(?:%s\.?,?\s+)?`, alternation(weekdayNames))
+		weekdaySuffixRegexPart = fmt.Sprintf(`(?:\s*[(（]%s[)）])?`, alternation(weekdayNames))
+		locale.weekdayPrefixRegex = regexp.MustCompile(fmt.Sprintf(`^%s\.?,?\s+`, alternation(weekdayNames)))
+		locale.weekdaySuffixRegex = regexp.MustCompile(fmt.Sprintf(`\s*[(（]%s[)）]$`, alternation(weekdayNames)))
+	}
+
+	dateFormats := make([]dateFormat, len(spec.DateFormats))
+
+	for i, format := range spec.DateFormats {
+		dateFormats[i] = dateFormat{
+			Layout:        format.Layout,
+			SwappedLayout: format.SwappedLayout,
+			Order:         format.Order,
+			regex: regexp.MustCompile(fmt.Sprintf(
+				`(%s%s%s)`,
+				weekdayPrefixRegexPart,
+				strings.ReplaceAll(format.Pattern, monthPlaceholder, monthRegexPart),
+				weekdaySuffixRegexPart,
+			)),
+		}
+	}
+
+	timeFormats := make([]timeFormat, len(spec.TimeFormats))
+
+	for i, format := range spec.TimeFormats {
+		timeFormats[i] = timeFormat{
+			Layout:      format.Layout,
+			HasTimeZone: format.HasTimeZone,
+			regex:       regexp.MustCompile(fmt.Sprintf(`(%s)`, format.Pattern)),
+		}
+	}
+
+	locale.attributionRegexes = make([]attributionRegex, len(spec.Attributions))
+
+	for i, regex := range spec.Attributions {
+		for _, part := range regex.Parts {
+			switch part {
+			case attributionRegexCaptureDate:
+				regex.DateFormats = dateFormats
+			case attributionRegexCaptureTime:
+				regex.TimeFormats = timeFormats
+			}
+		}
+
+		locale.attributionRegexes[i] = regex
+	}
+
+	return locale
+}
+
+// normalizeDate converts a date matched by one of the date formats in this
+// locale into a form that can be parsed by `time.Parse` using the layout of
+// that format, by removing the weekday and replacing the month name with its
+// abbreviated English name.
+func (l *Locale) normalizeDate(text string) string {
+	if l.weekdayPrefixRegex != nil {
+		text = l.weekdayPrefixRegex.ReplaceAllString(text, "")
+		text = l.weekdaySuffixRegex.ReplaceAllString(text, "")
+	}
+
+	if l.monthRegex != nil {
+		text = l.monthRegex.ReplaceAllStringFunc(text, func(match string) string {
+			name := l.monthRegex.FindStringSubmatch(match)[1]
+			return l.monthNames[strings.ToLower(name)].String()[:3]
+		})
+	}
+
+	return text
+}
+
+// Locales are ordered by how likely they are to be used, which is the order
+// they're tried in when auto-detecting the locale.
+var allLocales = []*Locale{
+	newLocale(englishLocaleSpec),
+	newLocale(germanLocaleSpec),
+	newLocale(frenchLocaleSpec),
+	newLocale(spanishLocaleSpec),
+	newLocale(portugueseLocaleSpec),
+	newLocale(dutchLocaleSpec),
+	newLocale(japaneseLocaleSpec),
+}
+
+// AllLocales returns every supported locale.
+func AllLocales() []*Locale {
+	locales := make([]*Locale, len(allLocales))
+	copy(locales, allLocales)
+
+	return locales
+}
+
+// LocaleByName returns the locale with the given name, which is a language
+// code like "de". This also accepts locale identifiers like "de_DE".
+func LocaleByName(name string) (*Locale, error) {
+	language := strings.ToLower(strings.SplitN(strings.ReplaceAll(name, "-", "_"), "_", 2)[0])
+
+	for _, locale := range allLocales {
+		if locale.Name == language {
+			return locale, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownLocale, name)
+}
+
+// DefaultLocale returns the locale used when no locale is configured.
+func DefaultLocale() *Locale {
+	return allLocales[0]
+}
+
+// The 24-hour time formats shared by most locales.
+var twentyFourHourTimeFormats = []timeFormatSpec{
+	{Layout: "15:04:05 -0700 (MST)", Pattern: `\d{2}:\d{2}:\d{2} [+-]\d{4} \([A-Z]{2,5}\)`, HasTimeZone: true},
+	{Layout: "15:04:05 -0700", Pattern: `\d{2}:\d{2}:\d{2} [+-]\d{4}`, HasTimeZone: true},
+	{Layout: "15:04:05", Pattern: `\d{1,2}:\d{2}:\d{2}`},
+	{Layout: "15:04", Pattern: `\d{1,2}:\d{2}`},
+}
+
+// The numeric date formats shared by locales which put the day before the
+// month.
+var dayFirstNumericDateFormats = []dateFormatSpec{
+	{Layout: "2006-01-02", Pattern: `\d{4}-\d{2}-\d{2}`},
+	{Layout: "2/1/2006", Pattern: `\d{1,2}/\d{1,2}/\d{4}`, Order: DateOrderDayFirst, SwappedLayout: "1/2/2006"},
+	{Layout: "2/1/06", Pattern: `\d{1,2}/\d{1,2}/\d{2}`, Order: DateOrderDayFirst, SwappedLayout: "1/2/06"},
+}
+
+// Attributions added by the Yahoo Groups web interface, which are in English
+// regardless of the language of the group.
+var yahooGroupsAttributions = []attributionRegex{
+	{
+		Template: `(?m)^%[1]s(?:-{2,3}\s+)?In\s+%[2]s,\s+%[3]s\s+wrote:\s+`,
+		Parts: []attributionRegexPart{
+			attributionRegexLiteral(nonNewlineWhitespaceRegexPart),
+			attributionRegexLiteral(attributionGroupEmailRegexPart),
+			attributionRegexCaptureName,
+		},
+		NameFormats: allNameFormats(),
+	},
+	{
+		Template: `(?m)^%[1]s-{2,3}\s+%[2]s\s+wrote:\s+`,
+		Parts: []attributionRegexPart{
+			attributionRegexLiteral(nonNewlineWhitespaceRegexPart),
+			attributionRegexCaptureName,
+		},
+		NameFormats: allNameFormats(),
+	},
+}
+
+// attributionWords are the words used in attribution lines in a locale.
+type attributionWords struct {
+	// The word for "On" in "On <date> at <time>, <name> wrote:".
+	On string
+
+	// The word for "at" in "On <date> at <time>, <name> wrote:". This is a
+	// regex, and it's optional in the attribution line.
+	At string
+
+	// A word which can optionally follow the time, like "Uhr" in German.
+	TimeSuffix string
+
+	// The word for "wrote" in "On <date> at <time>, <name> wrote:". This is a
+	// regex.
+	Wrote string
+
+	// The word for "In" in "In <group>, <name> wrote:", which is added by the
+	// localized Yahoo Groups web interface.
+	In string
+
+	// Whether the name comes after the verb, like "Am <date> schrieb <name>:".
+	VerbFirst bool
+}
+
+func (w attributionWords) subjectAndVerb() string {
+	if w.VerbFirst {
+		return fmt.Sprintf(`%s\s+%%[4]s`, w.Wrote)
+	}
+
+	return fmt.Sprintf(`%%[4]s\s+%s`, w.Wrote)
+}
+
+// localizedAttributions returns the attribution regexes common to most
+// locales.
+func localizedAttributions(words attributionWords) []attributionRegex {
+	timeSuffix := ""
+	if words.TimeSuffix != "" {
+		timeSuffix = fmt.Sprintf(`(?:\s+%s)?`, words.TimeSuffix)
+	}
+
+	dateOnlySubjectAndVerb := strings.ReplaceAll(words.subjectAndVerb(), "%[4]s", "%[3]s")
+
+	return []attributionRegex{
+		{
+			Template: fmt.Sprintf(`(?m)^%%[1]s(?:-{2,3}\s+)?%s\s+%%[2]s,?\s+(?:%s\s+)?%%[3]s%s,?\s+%s\s*:\s+`, words.On, words.At, timeSuffix, words.subjectAndVerb()),
+			Parts: []attributionRegexPart{
+				attributionRegexLiteral(nonNewlineWhitespaceRegexPart),
+				attributionRegexCaptureDate,
+				attributionRegexCaptureTime,
+				attributionRegexCaptureName,
+			},
+			NameFormats: allNameFormats(),
+		},
+		{
+			Template: fmt.Sprintf(`(?m)^%%[1]s(?:-{2,3}\s+)?%s\s+%%[2]s,?\s+%s\s*:\s+`, words.On, dateOnlySubjectAndVerb),
+			Parts: []attributionRegexPart{
+				attributionRegexLiteral(nonNewlineWhitespaceRegexPart),
+				attributionRegexCaptureDate,
+				attributionRegexCaptureName,
+			},
+			NameFormats: allNameFormats(),
+		},
+		{
+			Template: fmt.Sprintf(`(?m)^%%[1]s(?:-{2,3}\s+)?%s\s+%%[2]s,\s+%%[3]s\s+%s\s*:\s+`, words.In, words.Wrote),
+			Parts: []attributionRegexPart{
+				attributionRegexLiteral(nonNewlineWhitespaceRegexPart),
+				attributionRegexLiteral(attributionGroupEmailRegexPart),
+				attributionRegexCaptureName,
+			},
+			NameFormats: allNameFormats(),
+		},
+		{
+			Template: fmt.Sprintf(`(?m)^%%[1]s%%[2]s%%[1]s%s\s*:\s+`, words.Wrote),
+			Parts: []attributionRegexPart{
+				attributionRegexLiteral(nonNewlineWhitespaceRegexPart),
+				attributionRegexCaptureName,
+			},
+			// We only allow name formats that include an email address to reduce
+			// the likelihood of false positive matches on this pattern.
+			NameFormats: allEmailNameFormats(),
+		},
+	}
+}
+
+var englishLocaleSpec = localeSpec{
+	Name: LocaleEnglish,
+	Months: [12][]string{
+		{"January", "Jan"},
+		{"February", "Feb"},
+		{"March", "Mar"},
+		{"April", "Apr"},
+		{"May"},
+		{"June", "Jun"},
+		{"July", "Jul"},
+		{"August", "Aug"},
+		{"September", "Sept", "Sep"},
+		{"October", "Oct"},
+		{"November", "Nov"},
+		{"December", "Dec"},
+	},
+	Weekdays: [7][]string{
+		{"Sunday", "Sun"},
+		{"Monday", "Mon"},
+		{"Tuesday", "Tue"},
+		{"Wednesday", "Wed"},
+		{"Thursday", "Thu"},
+		{"Friday", "Fri"},
+		{"Saturday", "Sat"},
+	},
+	DateFormats: []dateFormatSpec{
+		{Layout: "2 Jan 2006", Pattern: `\d{1,2} %[1]s \d{4}`},
+		{Layout: "Jan 2, 2006", Pattern: `%[1]s \d{1,2}, \d{4}`},
+		{Layout: "2006-01-02", Pattern: `\d{4}-\d{2}-\d{2}`},
+		{Layout: "1/2/2006", Pattern: `\d{1,2}/\d{1,2}/\d{4}`, Order: DateOrderMonthFirst, SwappedLayout: "2/1/2006"},
+		{Layout: "1/2/06", Pattern: `\d{1,2}/\d{1,2}/\d{2}`, Order: DateOrderMonthFirst, SwappedLayout: "2/1/06"},
+	},
+	TimeFormats: []timeFormatSpec{
+		{Layout: "15:04:05 -0700 (MST)", Pattern: `\d{2}:\d{2}:\d{2} [+-]\d{4} \([A-Z]{2,5}\)`, HasTimeZone: true},
+		{Layout: "15:04:05 -0700", Pattern: `\d{2}:\d{2}:\d{2} [+-]\d{4}`, HasTimeZone: true},
+		{Layout: "3:04 PM", Pattern: `\d{1,2}:\d{2} (?:AM|PM)`},
+		{Layout: "15:04", Pattern: `\d{1,2}:\d{2}`},
+	},
+	Attributions: append(
+		[]attributionRegex{
+			{
+				Template: `(?m)^%[1]s(?:-{2,3}\s+)?On\s+%[2]s\s+(?:at\s+)?%[3]s,?\s+%[4]s\s+wrote:\s+`,
+				Parts: []attributionRegexPart{
+					attributionRegexLiteral(nonNewlineWhitespaceRegexPart),
+					attributionRegexCaptureDate,
+					attributionRegexCaptureTime,
+					attributionRegexCaptureName,
+				},
+				NameFormats: allNameFormats(),
+			},
+			{
+				Template: `(?m)^%[1]s(?:-{2,3}\s+)?On\s+%[2]s,?\s+%[3]s\s+wrote:\s+`,
+				Parts: []attributionRegexPart{
+					attributionRegexLiteral(nonNewlineWhitespaceRegexPart),
+					attributionRegexCaptureDate,
+					attributionRegexCaptureName,
+				},
+				NameFormats: allNameFormats(),
+			},
+		},
+		append(yahooGroupsAttributions, attributionRegex{
+			Template: `(?m)^%[1]s%[2]s%[1]swrote:\s+`,
+			Parts: []attributionRegexPart{
+				attributionRegexLiteral(nonNewlineWhitespaceRegexPart),
+				attributionRegexCaptureName,
+			},
+			// We only allow name formats that include an email address to reduce
+			// the likelihood of false positive matches on this pattern.
+			NameFormats: allEmailNameFormats(),
+		})...,
+	),
+}
+
+var germanLocaleSpec = localeSpec{
+	Name: LocaleGerman,
+	Months: [12][]string{
+		{"Januar", "Jänner", "Jan", "Jän"},
+		{"Februar", "Feb"},
+		{"März", "Mär", "Mrz"},
+		{"April", "Apr"},
+		{"Mai"},
+		{"Juni", "Jun"},
+		{"Juli", "Jul"},
+		{"August", "Aug"},
+		{"September", "Sept", "Sep"},
+		{"Oktober", "Okt"},
+		{"November", "Nov"},
+		{"Dezember", "Dez"},
+	},
+	Weekdays: [7][]string{
+		{"Sonntag", "So"},
+		{"Montag", "Mo"},
+		{"Dienstag", "Di"},
+		{"Mittwoch", "Mi"},
+		{"Donnerstag", "Do"},
+		{"Freitag", "Fr"},
+		{"Samstag", "Sonnabend", "Sa"},
+	},
+	DateFormats: []dateFormatSpec{
+		{Layout: "2. Jan 2006", Pattern: `\d{1,2}\. %[1]s \d{4}`},
+		{Layout: "2 Jan 2006", Pattern: `\d{1,2} %[1]s \d{4}`},
+		{Layout: "2006-01-02", Pattern: `\d{4}-\d{2}-\d{2}`},
+		{Layout: "2.1.2006", Pattern: `\d{1,2}\.\d{1,2}\.\d{4}`, Order: DateOrderDayFirst, SwappedLayout: "1.2.2006"},
+		{Layout: "2.1.06", Pattern: `\d{1,2}\.\d{1,2}\.\d{2}`, Order: DateOrderDayFirst, SwappedLayout: "1.2.06"},
+	},
+	TimeFormats:  twentyFourHourTimeFormats,
+	Attributions: append(localizedAttributions(attributionWords{On: "Am", At: "um", TimeSuffix: "Uhr", Wrote: "schrieb", In: "In", VerbFirst: true}), yahooGroupsAttributions...),
+}
+
+var frenchLocaleSpec = localeSpec{
+	Name: LocaleFrench,
+	Months: [12][]string{
+		{"janvier", "janv", "jan"},
+		{"février", "fevrier", "févr", "fevr", "fév", "fev"},
+		{"mars", "mar"},
+		{"avril", "avr"},
+		{"mai"},
+		{"juin"},
+		{"juillet", "juil"},
+		{"août", "aout"},
+		{"septembre", "sept", "sep"},
+		{"octobre", "oct"},
+		{"novembre", "nov"},
+		{"décembre", "decembre", "déc", "dec"},
+	},
+	Weekdays: [7][]string{
+		{"dimanche", "dim"},
+		{"lundi", "lun"},
+		{"mardi", "mar"},
+		{"mercredi", "mer"},
+		{"jeudi", "jeu"},
+		{"vendredi", "ven"},
+		{"samedi", "sam"},
+	},
+	DateFormats: append([]dateFormatSpec{
+		{Layout: "2 Jan 2006", Pattern: `\d{1,2} %[1]s \d{4}`},
+	}, dayFirstNumericDateFormats...),
+	TimeFormats: append([]timeFormatSpec{
+		{Layout: "15h04", Pattern: `\d{1,2}h\d{2}`},
+	}, twentyFourHourTimeFormats...),
+	Attributions: append(localizedAttributions(attributionWords{On: "Le", At: "à", Wrote: `a\s+écrit`, In: "Dans"}), yahooGroupsAttributions...),
+}
+
+var spanishLocaleSpec = localeSpec{
+	Name: LocaleSpanish,
+	Months: [12][]string{
+		{"enero", "ene"},
+		{"febrero", "feb"},
+		{"marzo", "mar"},
+		{"abril", "abr"},
+		{"mayo", "may"},
+		{"junio", "jun"},
+		{"julio", "jul"},
+		{"agosto", "ago"},
+		{"septiembre", "setiembre", "sept", "sep", "set"},
+		{"octubre", "oct"},
+		{"noviembre", "nov"},
+		{"diciembre", "dic"},
+	},
+	Weekdays: [7][]string{
+		{"domingo", "dom"},
+		{"lunes", "lun"},
+		{"martes", "mar"},
+		{"miércoles", "miercoles", "mié", "mie"},
+		{"jueves", "jue"},
+		{"viernes", "vie"},
+		{"sábado", "sabado", "sáb", "sab"},
+	},
+	DateFormats: append([]dateFormatSpec{
+		{Layout: "2 de Jan de 2006", Pattern: `\d{1,2} de %[1]s de \d{4}`},
+		{Layout: "2 Jan 2006", Pattern: `\d{1,2} %[1]s \d{4}`},
+	}, dayFirstNumericDateFormats...),
+	TimeFormats:  twentyFourHourTimeFormats,
+	Attributions: append(localizedAttributions(attributionWords{On: "El", At: `a\s+las?`, Wrote: "escribió", In: "En"}), yahooGroupsAttributions...),
+}
+
+var portugueseLocaleSpec = localeSpec{
+	Name: LocalePortuguese,
+	Months: [12][]string{
+		{"janeiro", "jan"},
+		{"fevereiro", "fev"},
+		{"março", "marco", "mar"},
+		{"abril", "abr"},
+		{"maio", "mai"},
+		{"junho", "jun"},
+		{"julho", "jul"},
+		{"agosto", "ago"},
+		{"setembro", "set"},
+		{"outubro", "out"},
+		{"novembro", "nov"},
+		{"dezembro", "dez"},
+	},
+	Weekdays: [7][]string{
+		{"domingo", "dom"},
+		{"segunda-feira", "segunda", "seg"},
+		{"terça-feira", "terca-feira", "terça", "terca", "ter"},
+		{"quarta-feira", "quarta", "qua"},
+		{"quinta-feira", "quinta", "qui"},
+		{"sexta-feira", "sexta", "sex"},
+		{"sábado", "sabado", "sáb", "sab"},
+	},
+	DateFormats: append([]dateFormatSpec{
+		{Layout: "2 de Jan de 2006", Pattern: `\d{1,2} de %[1]s de \d{4}`},
+		{Layout: "2 Jan 2006", Pattern: `\d{1,2} %[1]s \d{4}`},
+	}, dayFirstNumericDateFormats...),
+	TimeFormats:  twentyFourHourTimeFormats,
+	Attributions: append(localizedAttributions(attributionWords{On: "Em", At: "às", Wrote: "escreveu", In: "Em"}), yahooGroupsAttributions...),
+}
+
+var dutchLocaleSpec = localeSpec{
+	Name: LocaleDutch,
+	Months: [12][]string{
+		{"januari", "jan"},
+		{"februari", "feb"},
+		{"maart", "mrt", "mar"},
+		{"april", "apr"},
+		{"mei"},
+		{"juni", "jun"},
+		{"juli", "jul"},
+		{"augustus", "aug"},
+		{"september", "sept", "sep"},
+		{"oktober", "okt"},
+		{"november", "nov"},
+		{"december", "dec"},
+	},
+	Weekdays: [7][]string{
+		{"zondag", "zo"},
+		{"maandag", "ma"},
+		{"dinsdag", "di"},
+		{"woensdag", "wo"},
+		{"donderdag", "do"},
+		{"vrijdag", "vr"},
+		{"zaterdag", "za"},
+	},
+	DateFormats: append([]dateFormatSpec{
+		{Layout: "2 Jan 2006", Pattern: `\d{1,2} %[1]s \d{4}`},
+		{Layout: "2-1-2006", Pattern: `\d{1,2}-\d{1,2}-\d{4}`, Order: DateOrderDayFirst, SwappedLayout: "1-2-2006"},
+	}, dayFirstNumericDateFormats...),
+	TimeFormats:  twentyFourHourTimeFormats,
+	Attributions: append(localizedAttributions(attributionWords{On: "Op", At: "om", Wrote: "schreef", In: "In", VerbFirst: true}), yahooGroupsAttributions...),
+}
+
+var japaneseLocaleSpec = localeSpec{
+	Name: LocaleJapanese,
+	// Dates in Japanese are written with numeric months.
+	Months: [12][]string{},
+	Weekdays: [7][]string{
+		{"日曜日", "日"},
+		{"月曜日", "月"},
+		{"火曜日", "火"},
+		{"水曜日", "水"},
+		{"木曜日", "木"},
+		{"金曜日", "金"},
+		{"土曜日", "土"},
+	},
+	DateFormats: []dateFormatSpec{
+		{Layout: "2006年1月2日", Pattern: `\d{4}年\d{1,2}月\d{1,2}日`},
+		{Layout: "2006/1/2", Pattern: `\d{4}/\d{1,2}/\d{1,2}`},
+		{Layout: "2006-01-02", Pattern: `\d{4}-\d{2}-\d{2}`},
+	},
+	TimeFormats: twentyFourHourTimeFormats,
+	Attributions: append(
+		[]attributionRegex{
+			{
+				Template: `(?m)^%[1]s%[2]s\s+%[3]s\s*[、,]?\s*%[4]s\s*(?:さん)?(?:は書きました|のメッセージ)?\s*[:：]\s+`,
+				Parts: []attributionRegexPart{
+					attributionRegexLiteral(nonNewlineWhitespaceRegexPart),
+					attributionRegexCaptureDate,
+					attributionRegexCaptureTime,
+					attributionRegexCaptureName,
+				},
+				// Without the trailing text, this pattern is only a date, a time,
+				// and a name, so we require an email address.
+				NameFormats: allEmailNameFormats(),
+			},
+			{
+				Template: `(?m)^%[1]s%[2]s\s*さんは書きました\s*[:：]\s+`,
+				Parts: []attributionRegexPart{
+					attributionRegexLiteral(nonNewlineWhitespaceRegexPart),
+					attributionRegexCaptureName,
+				},
+				NameFormats: allNameFormats(),
+			},
+		},
+		yahooGroupsAttributions...,
+	),
+}
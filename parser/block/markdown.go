@@ -0,0 +1,113 @@
+package block
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Characters which are significant anywhere in a line of CommonMark.
+	markdownInlineEscaper = strings.NewReplacer(
+		`\`, `\\`,
+		"`", "\\`",
+		`*`, `\*`,
+		`_`, `\_`,
+		`[`, `\[`,
+		`]`, `\]`,
+		`<`, `\<`,
+		`>`, `\>`,
+		`!`, `\!`,
+		`|`, `\|`,
+		`~`, `\~`,
+		`&`, `\&`,
+		`#`, `\#`,
+	)
+
+	// Text at the start of a line which would start a list, a heading
+	// underline, or a thematic break.
+	markdownLineStartRegex = regexp.MustCompile(`(?m)^([\t ]*)([-+=]|\d+[.)])`)
+)
+
+// EscapeMarkdown escapes the characters in `text` which are significant in
+// CommonMark, so that it renders as the literal text.
+func EscapeMarkdown(text string) string {
+	escaped := markdownInlineEscaper.Replace(text)
+
+	return markdownLineStartRegex.ReplaceAllStringFunc(escaped, func(match string) string {
+		// Escape the last character of the match, which is the punctuation.
+		return match[:len(match)-1] + `\` + match[len(match)-1:]
+	})
+}
+
+// MarkdownLink wraps already-escaped text in a link if `href` isn't empty.
+func MarkdownLink(text, href string) string {
+	if href == "" {
+		return text
+	}
+
+	return fmt.Sprintf("[%s](<%s>)", text, strings.NewReplacer("<", "%3C", ">", "%3E").Replace(href))
+}
+
+// markdownFields renders a list of fields as lines of the form "**Name:**
+// value", separated by hard line breaks.
+func markdownFields(fields []Field) []string {
+	lines := make([]string, len(fields))
+
+	for i, field := range fields {
+		lines[i] = fmt.Sprintf("**%s:** %s", EscapeMarkdown(field.Name), EscapeMarkdown(field.Value))
+	}
+
+	return lines
+}
+
+func joinMarkdownLines(lines []string) string {
+	return strings.Join(lines, "\\\n")
+}
+
+func (b *MessageHeaderBlock) ToMarkdown() string {
+	lines := markdownFields(b.Fields)
+
+	if b.Href != "" {
+		lines = append(lines, MarkdownLink("View quoted message", b.Href))
+	}
+
+	return joinMarkdownLines(lines)
+}
+
+func (b *ForwardedBlock) ToMarkdown() string {
+	lines := append([]string{fmt.Sprintf("**%s**", EscapeMarkdown(b.Label))}, markdownFields(b.Fields)...)
+
+	if b.Href != "" {
+		lines = append(lines, MarkdownLink("View quoted message", b.Href))
+	}
+
+	return joinMarkdownLines(lines)
+}
+
+func (b *DividerBlock) ToMarkdown() string {
+	return "---"
+}
+
+func (b *AttributionBlock) ToMarkdown() string {
+	params := b.templateParams()
+
+	var text string
+
+	switch {
+	case params.DateAmbiguous && params.FormattedTime != "":
+		text = fmt.Sprintf("On %s, %s, %s said:", params.DateText, params.FormattedTime, params.Name)
+	case params.DateAmbiguous:
+		text = fmt.Sprintf("On %s, %s said:", params.DateText, params.Name)
+	case params.FormattedDatetime != "":
+		text = fmt.Sprintf("On %s, %s said:", params.FormattedDatetime, params.Name)
+	default:
+		text = fmt.Sprintf("%s said:", params.Name)
+	}
+
+	return fmt.Sprintf("*%s*", MarkdownLink(EscapeMarkdown(text), params.Href))
+}
+
+func (b *HardBreakBlock) ToMarkdown() string {
+	return ""
+}
@@ -18,18 +18,35 @@ var attributionTemplateString string
 
 var attributionTemplate = template.Must(template.New("attribution-block").Funcs(sprig.FuncMap()).Parse(attributionTemplateString))
 
+//go:embed forwarded.html.tmpl
+var forwardedTemplateString string
+
+var forwardedTemplate = template.Must(template.New("forwarded-block").Funcs(sprig.FuncMap()).Parse(forwardedTemplateString))
+
 type messageHeaderTemplateParams struct {
 	Fields []Field
+	Href   string
+}
+
+type forwardedTemplateParams struct {
+	Label  string
+	Fields []Field
+	Href   string
 }
 
 type attributionTemplateParams struct {
 	Name              string
 	FormattedDatetime string
 	Timestamp         string
+	DateAmbiguous     bool
+	DateText          string
+	FormattedTime     string
+	Href              string
+	NameHref          string
 }
 
 func (b *MessageHeaderBlock) ToHtml() string {
-	params := messageHeaderTemplateParams{Fields: *b}
+	params := messageHeaderTemplateParams{Fields: b.Fields, Href: b.Href}
 
 	var output strings.Builder
 
@@ -40,23 +57,57 @@ func (b *MessageHeaderBlock) ToHtml() string {
 	return strings.TrimSpace(output.String())
 }
 
+func (b *ForwardedBlock) ToHtml() string {
+	params := forwardedTemplateParams{Label: b.Label, Fields: b.Fields, Href: b.Href}
+
+	var output strings.Builder
+
+	if err := forwardedTemplate.Execute(&output, params); err != nil {
+		panic(err)
+	}
+
+	return strings.TrimSpace(output.String())
+}
+
 func (b *DividerBlock) ToHtml() string {
 	return "<hr>"
 }
 
-func (b *AttributionBlock) ToHtml() string {
-	params := attributionTemplateParams{Name: b.Name}
+func (b *AttributionBlock) templateParams() attributionTemplateParams {
+	params := attributionTemplateParams{Name: b.Name, Href: b.Href, NameHref: b.NameHref}
 
-	if !b.Time.IsZero() {
-		params.Timestamp = b.Time.Format(time.RFC3339)
+	if !b.Time.IsZero() && b.DateAmbiguous {
+		// We don't know which interpretation of the date is correct, so we show
+		// the date as it was written instead of a formatted date.
+		params.DateAmbiguous = true
+		params.DateText = b.DateText
 
 		if b.HasTime {
+			params.FormattedTime = b.Time.Format("15:04")
+		}
+	} else if !b.Time.IsZero() {
+		// We don't want to show a time or a time zone offset that wasn't in
+		// the attribution line and couldn't be resolved, because it would be
+		// made up.
+		switch {
+		case b.HasTime && b.HasTimeZone:
+			params.Timestamp = b.Time.Format(time.RFC3339)
 			params.FormattedDatetime = b.Time.Format("2 Jan 2006, 15:04 -07:00")
-		} else {
+		case b.HasTime:
+			params.Timestamp = b.Time.Format("2006-01-02T15:04:05")
+			params.FormattedDatetime = b.Time.Format("2 Jan 2006, 15:04")
+		default:
+			params.Timestamp = b.Time.Format("2006-01-02")
 			params.FormattedDatetime = b.Time.Format("2 Jan 2006")
 		}
 	}
 
+	return params
+}
+
+func (b *AttributionBlock) ToHtml() string {
+	params := b.templateParams()
+
 	var output strings.Builder
 
 	if err := attributionTemplate.Execute(&output, params); err != nil {
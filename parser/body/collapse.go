@@ -0,0 +1,149 @@
+package body
+
+import (
+	"github.com/acearchive/yahoo-groups-reader/block"
+	"strings"
+)
+
+// CollapseConfig determines which quotes are rendered as collapsed `<details>`
+// disclosures. A zero value for either threshold disables that threshold.
+type CollapseConfig struct {
+	// The minimum number of lines in a quote for it to be collapsed.
+	MinLines int
+
+	// The minimum nesting depth of a quote for it to be collapsed, where a
+	// top-level quote has a depth of 1.
+	MinDepth int
+}
+
+func (c CollapseConfig) IsEnabled() bool {
+	return c.MinLines > 0 || c.MinDepth > 0
+}
+
+func (c CollapseConfig) shouldCollapse(lines, depth int) bool {
+	return (c.MinLines > 0 && lines >= c.MinLines) || (c.MinDepth > 0 && depth >= c.MinDepth)
+}
+
+type StartCollapsedQuoteToken struct {
+	Attribution *block.AttributionBlock
+	Lines       int
+}
+
+func (StartCollapsedQuoteToken) TagType() TagType {
+	return TagTypeOpen
+}
+
+type EndCollapsedQuoteToken struct{}
+
+func (EndCollapsedQuoteToken) TagType() TagType {
+	return TagTypeClose
+}
+
+func countLines(text string) int {
+	trimmed := strings.TrimSpace(text)
+	if len(trimmed) == 0 {
+		return 0
+	}
+
+	return strings.Count(trimmed, "\n") + 1
+}
+
+// quoteLineCount returns the number of lines of text in the quote starting at
+// `startIndex`, including the lines of any nested quotes, along with the index
+// of the `EndQuoteToken` which closes it.
+func quoteLineCount(tokens []Token, startIndex int) (lines, endIndex int) {
+	depth := 0
+
+	for i := startIndex; i < len(tokens); i++ {
+		switch concrete := tokens[i].(type) {
+		case StartQuoteToken:
+			depth++
+		case EndQuoteToken:
+			depth--
+			if depth == 0 {
+				return lines, i
+			}
+		case TextToken:
+			lines += countLines(string(concrete))
+		}
+	}
+
+	return lines, len(tokens)
+}
+
+// CollapseQuotes replaces the quotes in `tokens` which pass the thresholds in
+// `config` with collapsed quotes. When an attribution immediately precedes a
+// collapsed quote, it's moved into the quote's summary. Quotes nested inside a
+// collapsed quote are left as-is.
+func CollapseQuotes(tokens []Token, config CollapseConfig) []Token {
+	if !config.IsEnabled() {
+		return tokens
+	}
+
+	output := make([]Token, 0, len(tokens))
+
+	// A stack of whether each open quote was collapsed, so we know which kind of
+	// token to close it with.
+	var collapsedStack []bool
+
+	collapsedDepth := 0
+
+	for i, token := range tokens {
+		switch token.(type) {
+		case StartQuoteToken:
+			lines, _ := quoteLineCount(tokens, i)
+			depth := len(collapsedStack) + 1
+
+			if collapsedDepth > 0 || !config.shouldCollapse(lines, depth) {
+				collapsedStack = append(collapsedStack, false)
+				output = append(output, token)
+
+				continue
+			}
+
+			collapsedToken := StartCollapsedQuoteToken{Lines: lines}
+
+			if len(output) > 0 {
+				if blockToken, isBlock := output[len(output)-1].(BlockToken); isBlock {
+					if attribution, isAttribution := blockToken.Block.(*block.AttributionBlock); isAttribution {
+						collapsedToken.Attribution = attribution
+						output = output[:len(output)-1]
+					}
+				}
+			}
+
+			collapsedStack = append(collapsedStack, true)
+			collapsedDepth++
+			output = append(output, collapsedToken)
+		case StartCollapsedQuoteToken:
+			// This quote was already collapsed, like when it duplicates the
+			// parent message.
+			collapsedStack = append(collapsedStack, true)
+			collapsedDepth++
+			output = append(output, token)
+		case EndCollapsedQuoteToken:
+			collapsedStack = collapsedStack[:len(collapsedStack)-1]
+			collapsedDepth--
+			output = append(output, token)
+		case EndQuoteToken:
+			if len(collapsedStack) == 0 {
+				output = append(output, token)
+				continue
+			}
+
+			wasCollapsed := collapsedStack[len(collapsedStack)-1]
+			collapsedStack = collapsedStack[:len(collapsedStack)-1]
+
+			if wasCollapsed {
+				collapsedDepth--
+				output = append(output, EndCollapsedQuoteToken{})
+			} else {
+				output = append(output, token)
+			}
+		default:
+			output = append(output, token)
+		}
+	}
+
+	return output
+}
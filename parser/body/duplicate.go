@@ -0,0 +1,164 @@
+package body
+
+import (
+	"errors"
+	"fmt"
+	"github.com/acearchive/yahoo-groups-reader/block"
+	"strings"
+	"unicode"
+)
+
+var ErrInvalidParentQuoteMode = errors.New("invalid parent quote mode")
+
+// ParentQuoteMode determines what's done with a quote at the end of a message
+// which duplicates the parent message, since the parent message is already
+// shown alongside the reply.
+type ParentQuoteMode string
+
+const (
+	ParentQuoteKeep     ParentQuoteMode = "keep"
+	ParentQuoteCollapse ParentQuoteMode = "collapse"
+	ParentQuoteDrop     ParentQuoteMode = "drop"
+)
+
+func ParseParentQuoteMode(input string) (ParentQuoteMode, error) {
+	switch mode := ParentQuoteMode(input); mode {
+	case ParentQuoteKeep, ParentQuoteCollapse, ParentQuoteDrop:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrInvalidParentQuoteMode, input)
+	}
+}
+
+const (
+	// The maximum number of lines of text that can follow a quote for it to
+	// still be considered at the end of the message, to allow for a signature.
+	maxTrailingLines = 3
+
+	// The minimum fraction of the word pairs in a quote which must appear in
+	// the parent message, and the minimum fraction of the word pairs in the
+	// parent message which must appear in the quote, for the quote to be
+	// considered a duplicate of the parent message.
+	minQuoteContainment = 0.8
+	minParentCoverage   = 0.5
+)
+
+// wordPairs returns the set of pairs of consecutive words in `text`, ignoring
+// case and punctuation, which is tolerant of the rewrapping mail clients do
+// when quoting a message.
+func wordPairs(text string) map[string]struct{} {
+	words := strings.FieldsFunc(strings.ToLower(text), func(char rune) bool {
+		return !unicode.IsLetter(char) && !unicode.IsDigit(char)
+	})
+
+	pairs := make(map[string]struct{}, len(words))
+
+	for i := 0; i+1 < len(words); i++ {
+		pairs[words[i]+" "+words[i+1]] = struct{}{}
+	}
+
+	return pairs
+}
+
+func tokensText(tokens []Token) string {
+	var builder strings.Builder
+
+	for _, token := range tokens {
+		if textToken, isText := token.(TextToken); isText {
+			builder.WriteString(string(textToken))
+			builder.WriteString("\n")
+		}
+	}
+
+	return builder.String()
+}
+
+// trailingQuote returns the indices of the `StartQuoteToken` and the
+// `EndQuoteToken` of the last top-level quote in `tokens`, if nothing but a
+// short signature follows it.
+func trailingQuote(tokens []Token) (startIndex, endIndex int, ok bool) {
+	startIndex = -1
+	depth := 0
+
+	for i, token := range tokens {
+		switch token.(type) {
+		case StartQuoteToken:
+			if depth == 0 {
+				if lines, quoteEndIndex := quoteLineCount(tokens, i); lines > 0 && quoteEndIndex < len(tokens) {
+					startIndex, endIndex = i, quoteEndIndex
+				}
+			}
+
+			depth++
+		case EndQuoteToken:
+			depth--
+		}
+	}
+
+	if startIndex < 0 || countLines(tokensText(tokens[endIndex+1:])) > maxTrailingLines {
+		return 0, 0, false
+	}
+
+	return startIndex, endIndex, true
+}
+
+// isDuplicateOf returns whether the text of `quote` is mostly the text of
+// `parent`.
+func isDuplicateOf(quote, parent []Token) bool {
+	quotePairs, parentPairs := wordPairs(tokensText(quote)), wordPairs(tokensText(parent))
+	if len(quotePairs) == 0 || len(parentPairs) == 0 {
+		return false
+	}
+
+	shared := 0
+
+	for pair := range quotePairs {
+		if _, isShared := parentPairs[pair]; isShared {
+			shared++
+		}
+	}
+
+	return float64(shared) >= minQuoteContainment*float64(len(quotePairs)) &&
+		float64(shared) >= minParentCoverage*float64(len(parentPairs))
+}
+
+// StripParentQuote drops or collapses the quote at the end of `tokens` if it
+// duplicates `parentTokens`, along with the attribution line before it. This
+// returns a new slice and leaves `tokens` as-is. An empty mode is the same as
+// `ParentQuoteKeep`.
+func StripParentQuote(tokens, parentTokens []Token, mode ParentQuoteMode) []Token {
+	if mode == "" || mode == ParentQuoteKeep || len(parentTokens) == 0 {
+		return tokens
+	}
+
+	startIndex, endIndex, ok := trailingQuote(tokens)
+	if !ok || !isDuplicateOf(tokens[startIndex:endIndex+1], parentTokens) {
+		return tokens
+	}
+
+	var attribution *block.AttributionBlock
+
+	beforeIndex := startIndex
+
+	if startIndex > 0 {
+		if blockToken, isBlock := tokens[startIndex-1].(BlockToken); isBlock {
+			if attributionBlock, isAttribution := blockToken.Block.(*block.AttributionBlock); isAttribution {
+				attribution = attributionBlock
+				beforeIndex--
+			}
+		}
+	}
+
+	output := make([]Token, 0, len(tokens))
+	output = append(output, tokens[:beforeIndex]...)
+
+	if mode == ParentQuoteCollapse {
+		lines, _ := quoteLineCount(tokens, startIndex)
+
+		output = append(output, StartCollapsedQuoteToken{Attribution: attribution, Lines: lines})
+		output = append(output, tokens[startIndex+1:endIndex]...)
+		output = append(output, EndCollapsedQuoteToken{})
+	}
+
+	return append(output, tokens[endIndex+1:]...)
+}
@@ -0,0 +1,73 @@
+package body
+
+import "github.com/acearchive/yahoo-groups-reader/block"
+
+type StartForwardedToken struct {
+	Block *block.ForwardedBlock
+}
+
+func (StartForwardedToken) TagType() TagType {
+	return TagTypeOpen
+}
+
+type EndForwardedToken struct{}
+
+func (EndForwardedToken) TagType() TagType {
+	return TagTypeClose
+}
+
+// nestForwardedSections replaces each `ForwardedBlock` in `tokens` with a
+// section containing everything after it up to the end of the enclosing quote
+// or message, which is the body of the forwarded message.
+func nestForwardedSections(tokens []Token) []Token {
+	output := make([]Token, 0, len(tokens))
+
+	quoteDepth := 0
+
+	// The quote depth that each open forwarded section was started at.
+	var sectionDepths []int
+
+	closeSectionsAbove := func(depth int) {
+		for len(sectionDepths) > 0 && sectionDepths[len(sectionDepths)-1] > depth {
+			sectionDepths = sectionDepths[:len(sectionDepths)-1]
+			output = append(output, EndForwardedToken{})
+		}
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		switch concrete := tokens[i].(type) {
+		case StartQuoteToken:
+			quoteDepth++
+			output = append(output, concrete)
+		case EndQuoteToken:
+			quoteDepth--
+			closeSectionsAbove(quoteDepth)
+			output = append(output, concrete)
+		case BlockToken:
+			forwarded, isForwarded := concrete.Block.(*block.ForwardedBlock)
+			if !isForwarded {
+				output = append(output, concrete)
+				continue
+			}
+
+			// The header list is sometimes separated from the banner by a blank
+			// line, in which case it's parsed as a separate block.
+			if i+1 < len(tokens) {
+				if next, isBlock := tokens[i+1].(BlockToken); isBlock {
+					if header, isHeader := next.Block.(*block.MessageHeaderBlock); isHeader && forwarded.AbsorbHeader(header) {
+						i++
+					}
+				}
+			}
+
+			sectionDepths = append(sectionDepths, quoteDepth)
+			output = append(output, StartForwardedToken{Block: forwarded})
+		default:
+			output = append(output, concrete)
+		}
+	}
+
+	closeSectionsAbove(-1)
+
+	return output
+}
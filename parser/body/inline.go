@@ -0,0 +1,139 @@
+package body
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	inlineLinkRegex = regexp.MustCompile(`(?:https?|ftp)://[^\s<>"]+`)
+
+	// Plain text emails emphasize words by surrounding them with asterisks or
+	// underscores, like *this* or _this_. The delimiters have to be at the
+	// edges of a word, so that things like file_name_here aren't emphasized.
+	inlineEmphasisRegex = regexp.MustCompile(`(?:^|[^\w*])(\*([^\s*](?:[^*\n]*[^\s*])?)\*|_([^\s_](?:[^_\n]*[^\s_])?)_)(?:$|[^\w*])`)
+)
+
+// The characters which usually end the sentence a link is in rather than
+// being part of the link.
+const linkTrailingPunctuation = `.,;:!?'"`
+
+// StartLinkToken opens an inline hyperlink.
+type StartLinkToken struct {
+	Href string
+}
+
+func (StartLinkToken) TagType() TagType {
+	return TagTypeOpen
+}
+
+type EndLinkToken struct{}
+
+func (EndLinkToken) TagType() TagType {
+	return TagTypeClose
+}
+
+// StartEmphasisToken opens inline emphasized text.
+type StartEmphasisToken struct{}
+
+func (StartEmphasisToken) TagType() TagType {
+	return TagTypeOpen
+}
+
+type EndEmphasisToken struct{}
+
+func (EndEmphasisToken) TagType() TagType {
+	return TagTypeClose
+}
+
+// inlineHtml returns the HTML for a token which is rendered on the same line
+// as the text around it, or false if the token isn't inline.
+func inlineHtml(token Token) (string, bool) {
+	switch concreteToken := token.(type) {
+	case TextToken:
+		return html.EscapeString(string(concreteToken)), true
+	case StartLinkToken, EndLinkToken, StartEmphasisToken, EndEmphasisToken:
+		return concreteToken.ToHtml(), true
+	default:
+		return "", false
+	}
+}
+
+// trimLink trims the punctuation from the end of a URL found in text which
+// most likely isn't part of it, including a closing parenthesis when the URL
+// was written inside parentheses.
+func trimLink(url string) string {
+	for {
+		trimmed := strings.TrimRight(url, linkTrailingPunctuation)
+
+		if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, "(") < strings.Count(trimmed, ")") {
+			trimmed = strings.TrimSuffix(trimmed, ")")
+		}
+
+		if trimmed == url {
+			return url
+		}
+
+		url = trimmed
+	}
+}
+
+// emphasisNodes splits `text` into text and emphasis nodes.
+func emphasisNodes(text string) []Node {
+	var nodes []Node
+
+	for {
+		match := inlineEmphasisRegex.FindStringSubmatchIndex(text)
+		if match == nil {
+			break
+		}
+
+		delimitedStart, delimitedEnd := match[2], match[3]
+
+		contentStart, contentEnd := match[4], match[5]
+		if contentStart < 0 {
+			contentStart, contentEnd = match[6], match[7]
+		}
+
+		if delimitedStart > 0 {
+			nodes = append(nodes, &Text{Content: text[:delimitedStart]})
+		}
+
+		nodes = append(nodes, &Emphasis{Children: []Node{&Text{Content: text[contentStart:contentEnd]}}})
+
+		// The character after the closing delimiter can be the start of the
+		// next emphasized word, so we don't skip past it.
+		text = text[delimitedEnd:]
+	}
+
+	if text != "" {
+		nodes = append(nodes, &Text{Content: text})
+	}
+
+	return nodes
+}
+
+// inlineNodes splits the text of a paragraph into inline nodes, linking URLs
+// and emphasizing words that were written to be emphasized.
+func inlineNodes(text string) []Node {
+	var nodes []Node
+
+	for {
+		match := inlineLinkRegex.FindStringIndex(text)
+		if match == nil {
+			break
+		}
+
+		linkStart := match[0]
+		url := trimLink(text[linkStart:match[1]])
+		linkEnd := linkStart + len(url)
+
+		nodes = append(nodes, emphasisNodes(text[:linkStart])...)
+		nodes = append(nodes, &Link{Href: url, Children: []Node{&Text{Content: url}}})
+
+		text = text[linkEnd:]
+	}
+
+	return append(nodes, emphasisNodes(text)...)
+}
@@ -0,0 +1,36 @@
+package body
+
+import "testing"
+
+func TestInlineNodes(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "plain text", text: "Nothing to see here.", want: "Nothing to see here."},
+		{name: "link", text: "See http://example.com/a for more.", want: `See <a href="http://example.com/a">http://example.com/a</a> for more.`},
+		{name: "link before punctuation", text: "(http://example.com/a).", want: `(<a href="http://example.com/a">http://example.com/a</a>).`},
+		{name: "link with parentheses", text: "http://example.com/a_(b)", want: `<a href="http://example.com/a_(b)">http://example.com/a_(b)</a>`},
+		{name: "asterisks", text: "It was *really* good.", want: "It was <em>really</em> good."},
+		{name: "underscores", text: "It was _really_ good.", want: "It was <em>really</em> good."},
+		{name: "adjacent emphasis", text: "*a* *b*", want: "<em>a</em> <em>b</em>"},
+		{name: "inside a word", text: "file_name_here", want: "file_name_here"},
+		{name: "between digits", text: "2*3*4", want: "2*3*4"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			document, err := NewDocument([]Token{StartParagraphToken{}, TextToken(test.text + "\n"), EndParagraphToken{}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := "<p>\n  " + test.want + "\n</p>\n"
+
+			if got := Render(document.Tokens()); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package body
+
+import "github.com/acearchive/yahoo-groups-reader/block"
+
+// LinkableBlocks returns the blocks in `tokens` which can link to the message
+// they quote, including the blocks which introduce forwarded sections.
+func LinkableBlocks(tokens []Token) []block.LinkableBlock {
+	var blocks []block.LinkableBlock
+
+	for _, token := range tokens {
+		switch concreteToken := token.(type) {
+		case BlockToken:
+			if linkable, isLinkable := concreteToken.Block.(block.LinkableBlock); isLinkable {
+				blocks = append(blocks, linkable)
+			}
+		case StartForwardedToken:
+			blocks = append(blocks, concreteToken.Block)
+		}
+	}
+
+	return blocks
+}
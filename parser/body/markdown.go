@@ -0,0 +1,89 @@
+package body
+
+import (
+	"fmt"
+	"github.com/acearchive/yahoo-groups-reader/block"
+	"strings"
+)
+
+const markdownQuotePrefix = ">"
+
+// prefixLines prefixes each line in `text` with a quote marker.
+func prefixLines(text string) string {
+	lines := strings.Split(text, "\n")
+
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = markdownQuotePrefix
+		} else {
+			lines[i] = markdownQuotePrefix + " " + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// inlineMarkdown renders inline nodes, which are the contents of a paragraph.
+func inlineMarkdown(nodes []Node) string {
+	var builder strings.Builder
+
+	for _, node := range nodes {
+		switch concreteNode := node.(type) {
+		case *Text:
+			builder.WriteString(block.EscapeMarkdown(concreteNode.Content))
+		case *Emphasis:
+			builder.WriteString("*" + inlineMarkdown(concreteNode.Children) + "*")
+		case *Link:
+			builder.WriteString(block.MarkdownLink(inlineMarkdown(concreteNode.Children), concreteNode.Href))
+		}
+	}
+
+	return builder.String()
+}
+
+// blockMarkdown renders block-level nodes, separating them with blank lines.
+func blockMarkdown(nodes []Node) string {
+	var sections []string
+
+	for _, node := range nodes {
+		var section string
+
+		switch concreteNode := node.(type) {
+		case *Paragraph:
+			section = strings.TrimSpace(inlineMarkdown(concreteNode.Children))
+		case *Quote:
+			section = prefixLines(blockMarkdown(concreteNode.Children))
+		case *CollapsedQuote:
+			if concreteNode.Attribution != nil {
+				sections = append(sections, concreteNode.Attribution.ToMarkdown())
+			}
+
+			section = prefixLines(blockMarkdown(concreteNode.Children))
+		case *ForwardedSection:
+			section = blockMarkdown(append([]Node{&BlockNode{Block: concreteNode.Block}}, concreteNode.Children...))
+		case *BlockNode:
+			section = concreteNode.Block.ToMarkdown()
+		case *QuoteSourceNode:
+			if concreteNode.Source.Href != "" {
+				section = block.MarkdownLink(fmt.Sprintf("Quoting from message \\#%d", concreteNode.Source.Number), concreteNode.Source.Href)
+			}
+		}
+
+		if section != "" {
+			sections = append(sections, section)
+		}
+	}
+
+	return strings.Join(sections, "\n\n")
+}
+
+// RenderMarkdown renders a message body as CommonMark. Quotes are rendered
+// with `>` prefixes, and text is escaped so that it renders literally.
+func RenderMarkdown(tokens []Token) (string, error) {
+	document, err := NewDocument(tokens)
+	if err != nil {
+		return "", err
+	}
+
+	return blockMarkdown(document.Children) + "\n", nil
+}
@@ -0,0 +1,17 @@
+package body
+
+import "testing"
+
+func TestRenderMarkdownInline(t *testing.T) {
+	tokens := []Token{StartParagraphToken{}, TextToken("See http://example.com/a_b, *now*.\n"), EndParagraphToken{}}
+	want := "See [http://example.com/a\\_b](<http://example.com/a_b>), *now*.\n"
+
+	got, err := RenderMarkdown(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
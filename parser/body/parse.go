@@ -115,10 +115,32 @@ func ParseLines(text io.Reader) ([]Line, error) {
 	return lines, nil
 }
 
+// BlockMatch is a block found by the tokenizer, for debugging how a message is
+// parsed.
+type BlockMatch struct {
+	Block block.Block
+
+	// The name of the pattern which matched the block, for blocks which have
+	// more than one.
+	Pattern string
+
+	// The index of the paragraph the block was found in, counting from 0.
+	Paragraph int
+
+	// The byte offsets of the block in the text of the paragraph.
+	Start int
+	End   int
+
+	// The text of the paragraph which the block matched.
+	Text string
+}
+
 type Tokenizer struct {
 	previousLine      Line
 	currentQuoteDepth int
 	blockFactory      func() []block.Block
+	traceBlock        func(match BlockMatch)
+	paragraphIndex    int
 }
 
 func NewTokenizer(blockFactory func() []block.Block) Tokenizer {
@@ -129,6 +151,12 @@ func NewTokenizer(blockFactory func() []block.Block) Tokenizer {
 	return tokenizer
 }
 
+// TraceBlocks sets a function which is called with each block the tokenizer
+// finds.
+func (t *Tokenizer) TraceBlocks(trace func(match BlockMatch)) {
+	t.traceBlock = trace
+}
+
 func NewDefaultTokenizer() Tokenizer {
 	return NewTokenizer(block.AllBlocks)
 }
@@ -136,6 +164,7 @@ func NewDefaultTokenizer() Tokenizer {
 func (t *Tokenizer) reset() {
 	t.previousLine = Line{Content: "", QuoteDepth: 0}
 	t.currentQuoteDepth = 0
+	t.paragraphIndex = 0
 }
 
 func (t *Tokenizer) rawTokenizeLine(line Line) []Token {
@@ -190,7 +219,11 @@ func (t *Tokenizer) TokenizeLines(lines []Line) []Token {
 		tokens = append(tokens, t.rawTokenizeLine(line)...)
 	}
 
-	return t.parseBlocks(tokens)
+	// Close any paragraphs and quotes which are still open at the end of the
+	// message.
+	tokens = append(tokens, t.rawTokenizeLine(Line{Content: "", QuoteDepth: 0})...)
+
+	return nestForwardedSections(t.parseBlocks(tokens))
 }
 
 func (t *Tokenizer) Tokenize(body io.Reader) ([]Token, error) {
@@ -202,11 +235,24 @@ func (t *Tokenizer) Tokenize(body io.Reader) ([]Token, error) {
 	return t.TokenizeLines(lines), nil
 }
 
-func (t Tokenizer) findBlocksInParagraph(text string) []Token {
+// findBlocksInParagraph finds the blocks in `text`, which starts at byte
+// `offset` in the current paragraph.
+func (t Tokenizer) findBlocksInParagraph(text string, offset int) []Token {
 	for _, newBlock := range t.blockFactory() {
 		if ok, before, after := newBlock.FromText(text); ok {
-			beforeBlocks := t.findBlocksInParagraph(before)
-			afterBlocks := t.findBlocksInParagraph(after)
+			if t.traceBlock != nil {
+				t.traceBlock(BlockMatch{
+					Block:     newBlock,
+					Pattern:   block.MatchedPattern(newBlock),
+					Paragraph: t.paragraphIndex,
+					Start:     offset + len(before),
+					End:       offset + len(text) - len(after),
+					Text:      text[len(before) : len(text)-len(after)],
+				})
+			}
+
+			beforeBlocks := t.findBlocksInParagraph(before, offset)
+			afterBlocks := t.findBlocksInParagraph(after, offset+len(text)-len(after))
 
 			output := make([]Token, 0, len(beforeBlocks)+len(afterBlocks)+1)
 			output = append(output, beforeBlocks...)
@@ -228,7 +274,7 @@ func (t Tokenizer) findBlocksInParagraph(text string) []Token {
 	}
 }
 
-func (t Tokenizer) parseBlocks(tokens []Token) []Token {
+func (t *Tokenizer) parseBlocks(tokens []Token) []Token {
 	output := make([]Token, 0, len(tokens))
 
 	var currentParagraph strings.Builder
@@ -238,7 +284,8 @@ func (t Tokenizer) parseBlocks(tokens []Token) []Token {
 		case StartParagraphToken:
 			currentParagraph.Reset()
 		case EndParagraphToken:
-			output = append(output, t.findBlocksInParagraph(currentParagraph.String())...)
+			output = append(output, t.findBlocksInParagraph(currentParagraph.String(), 0)...)
+			t.paragraphIndex++
 		case TextToken:
 			currentParagraph.WriteString(string(concrete))
 			currentParagraph.WriteString("\n")
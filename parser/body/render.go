@@ -1,11 +1,29 @@
 package body
 
 import (
+	_ "embed"
+	"fmt"
+	"github.com/Masterminds/sprig/v3"
 	"html"
+	"html/template"
 	"strings"
 )
 
-const IndentLen = 2
+const (
+	IndentLen                   = 2
+	collapsedQuoteSummaryIndent = 4
+	forwardedSectionIndent      = 2
+)
+
+//go:embed collapse.html.tmpl
+var collapsedQuoteTemplateString string
+
+var collapsedQuoteTemplate = template.Must(template.New("collapsed-quote").Funcs(sprig.FuncMap()).Parse(collapsedQuoteTemplateString))
+
+type collapsedQuoteTemplateParams struct {
+	Attribution template.HTML
+	Lines       int
+}
 
 func IndentMultilineString(text string, indent int) string {
 	var output strings.Builder
@@ -40,6 +58,46 @@ func (EndQuoteToken) ToHtml() string {
 	return "</blockquote>"
 }
 
+func (t StartCollapsedQuoteToken) ToHtml() string {
+	params := collapsedQuoteTemplateParams{Lines: t.Lines}
+
+	if t.Attribution != nil {
+		params.Attribution = template.HTML(strings.TrimSpace(IndentMultilineString(t.Attribution.ToHtml(), collapsedQuoteSummaryIndent)))
+	}
+
+	return executeCollapsedQuoteTemplate("start", params)
+}
+
+func (EndCollapsedQuoteToken) ToHtml() string {
+	return executeCollapsedQuoteTemplate("end", nil)
+}
+
+// executeCollapsedQuoteTemplate renders one half of a collapsed quote. Both
+// halves are defined in the same template file so that they stay balanced.
+func executeCollapsedQuoteTemplate(name string, params interface{}) string {
+	var output strings.Builder
+
+	if err := collapsedQuoteTemplate.ExecuteTemplate(&output, name, params); err != nil {
+		panic(err)
+	}
+
+	return strings.Trim(output.String(), "\n")
+}
+
+func (t StartForwardedToken) ToHtml() string {
+	var output strings.Builder
+
+	output.WriteString("<section class=\"forwarded-message\">\n")
+	output.WriteString(IndentMultilineString(t.Block.ToHtml(), forwardedSectionIndent))
+	output.WriteString("  <div class=\"forwarded-body\">")
+
+	return output.String()
+}
+
+func (EndForwardedToken) ToHtml() string {
+	return "  </div>\n</section>"
+}
+
 func (b BlockToken) ToHtml() string {
 	return b.Block.ToHtml()
 }
@@ -48,16 +106,59 @@ func (t TextToken) ToHtml() string {
 	return html.EscapeString(strings.TrimSpace(string(t)))
 }
 
+func (t StartLinkToken) ToHtml() string {
+	return fmt.Sprintf("<a href=\"%s\">", html.EscapeString(t.Href))
+}
+
+func (EndLinkToken) ToHtml() string {
+	return "</a>"
+}
+
+func (StartEmphasisToken) ToHtml() string {
+	return "<em>"
+}
+
+func (EndEmphasisToken) ToHtml() string {
+	return "</em>"
+}
+
 func Render(tokens []Token) string {
 	var output strings.Builder
 
 	indentLevel := 0
 
 	writeToken := func(token Token) {
-		output.WriteString(IndentMultilineString(token.ToHtml(), indentLevel*IndentLen))
+		tokenHtml := token.ToHtml()
+
+		// Some tokens, like links to quoted messages which couldn't be
+		// resolved, don't render anything.
+		if tokenHtml == "" {
+			return
+		}
+
+		output.WriteString(IndentMultilineString(tokenHtml, indentLevel*IndentLen))
+	}
+
+	// Text and the inline tokens around it are written together, so that no
+	// whitespace is added between them.
+	var inline strings.Builder
+
+	flushInline := func() {
+		if inlineText := strings.TrimSpace(inline.String()); inlineText != "" {
+			output.WriteString(IndentMultilineString(inlineText, indentLevel*IndentLen))
+		}
+
+		inline.Reset()
 	}
 
 	for _, token := range tokens {
+		if tokenHtml, isInline := inlineHtml(token); isInline {
+			inline.WriteString(tokenHtml)
+			continue
+		}
+
+		flushInline()
+
 		switch token.TagType() {
 		case TagTypeOpen:
 			writeToken(token)
@@ -70,5 +171,7 @@ func Render(tokens []Token) string {
 		}
 	}
 
+	flushInline()
+
 	return output.String()
 }
@@ -0,0 +1,53 @@
+package body
+
+import (
+	"fmt"
+	"github.com/acearchive/yahoo-groups-reader/block"
+	"html"
+)
+
+// QuoteSource is the message that a quote was found to come from by matching
+// its text against earlier messages, for quotes which don't have an attribution
+// line to link instead.
+type QuoteSource struct {
+	block.QuoteLink
+
+	// The number of the quoted message in the archive. This is set along with
+	// the URL.
+	Number int
+}
+
+// QuoteSourceToken is a link to the source of the quote which follows it.
+type QuoteSourceToken struct {
+	Source *QuoteSource
+}
+
+func (QuoteSourceToken) TagType() TagType {
+	return TagTypeSelfClose
+}
+
+func (t QuoteSourceToken) ToHtml() string {
+	if t.Source.Href == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(
+		"<div class=\"quote-source\">\n  <a class=\"quote-source-link\" href=\"%s\">Quoting from message #%d</a>\n</div>",
+		html.EscapeString(t.Source.Href),
+		t.Source.Number,
+	)
+}
+
+// QuoteSources returns the sources of the quotes in `tokens` which were found
+// by matching their text.
+func QuoteSources(tokens []Token) []*QuoteSource {
+	var sources []*QuoteSource
+
+	for _, token := range tokens {
+		if sourceToken, isSource := token.(QuoteSourceToken); isSource {
+			sources = append(sources, sourceToken.Source)
+		}
+	}
+
+	return sources
+}
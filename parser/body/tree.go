@@ -0,0 +1,269 @@
+package body
+
+import (
+	"errors"
+	"fmt"
+	"github.com/acearchive/yahoo-groups-reader/block"
+)
+
+var ErrUnbalancedTokens = errors.New("unbalanced tokens")
+
+// Node is a node in the document tree of a message body. The tree is built
+// from the token stream produced by the tokenizer, and can be converted back
+// to tokens.
+type Node interface {
+	// Tokens returns the tokens for this node and everything nested in it.
+	Tokens() []Token
+}
+
+// ParentNode is a node which contains other nodes.
+type ParentNode interface {
+	Node
+	ChildNodes() []Node
+	appendChild(child Node)
+}
+
+// Document is the root of the document tree of a message body.
+type Document struct {
+	Children []Node
+}
+
+// Paragraph is a paragraph of text, which contains inline nodes.
+type Paragraph struct {
+	Children []Node
+}
+
+// Quote is a quoted section of the message.
+type Quote struct {
+	Children []Node
+}
+
+// CollapsedQuote is a quote which is collapsed by default, along with the
+// attribution line that was moved into its summary, if there was one.
+type CollapsedQuote struct {
+	Attribution *block.AttributionBlock
+	Lines       int
+	Children    []Node
+}
+
+// ForwardedSection is a forwarded message, which is introduced by a
+// `ForwardedBlock` and contains the body of the forwarded message.
+type ForwardedSection struct {
+	Block    *block.ForwardedBlock
+	Children []Node
+}
+
+// BlockNode is a block like an attribution line or a divider.
+type BlockNode struct {
+	Block block.Block
+}
+
+// QuoteSourceNode is a link to the source of the quote which follows it.
+type QuoteSourceNode struct {
+	Source *QuoteSource
+}
+
+// Text is a run of plain text.
+type Text struct {
+	Content string
+}
+
+// Link is an inline hyperlink, which is made from a URL in the text.
+type Link struct {
+	Href     string
+	Children []Node
+}
+
+// Emphasis is inline emphasized text, which is made from text surrounded by
+// asterisks or underscores.
+type Emphasis struct {
+	Children []Node
+}
+
+func childTokens(children []Node) []Token {
+	var tokens []Token
+
+	for _, child := range children {
+		tokens = append(tokens, child.Tokens()...)
+	}
+
+	return tokens
+}
+
+func wrapTokens(start Token, children []Node, end Token) []Token {
+	tokens := []Token{start}
+	tokens = append(tokens, childTokens(children)...)
+
+	return append(tokens, end)
+}
+
+func (n *Document) Tokens() []Token {
+	return childTokens(n.Children)
+}
+
+func (n *Paragraph) Tokens() []Token {
+	return wrapTokens(StartParagraphToken{}, n.Children, EndParagraphToken{})
+}
+
+func (n *Quote) Tokens() []Token {
+	return wrapTokens(StartQuoteToken{}, n.Children, EndQuoteToken{})
+}
+
+func (n *CollapsedQuote) Tokens() []Token {
+	return wrapTokens(StartCollapsedQuoteToken{Attribution: n.Attribution, Lines: n.Lines}, n.Children, EndCollapsedQuoteToken{})
+}
+
+func (n *ForwardedSection) Tokens() []Token {
+	return wrapTokens(StartForwardedToken{Block: n.Block}, n.Children, EndForwardedToken{})
+}
+
+func (n *BlockNode) Tokens() []Token {
+	return []Token{BlockToken{n.Block}}
+}
+
+func (n *QuoteSourceNode) Tokens() []Token {
+	return []Token{QuoteSourceToken{Source: n.Source}}
+}
+
+func (n *Text) Tokens() []Token {
+	return []Token{TextToken(n.Content)}
+}
+
+func (n *Link) Tokens() []Token {
+	return wrapTokens(StartLinkToken{Href: n.Href}, n.Children, EndLinkToken{})
+}
+
+func (n *Emphasis) Tokens() []Token {
+	return wrapTokens(StartEmphasisToken{}, n.Children, EndEmphasisToken{})
+}
+
+func (n *Document) ChildNodes() []Node         { return n.Children }
+func (n *Paragraph) ChildNodes() []Node        { return n.Children }
+func (n *Quote) ChildNodes() []Node            { return n.Children }
+func (n *CollapsedQuote) ChildNodes() []Node   { return n.Children }
+func (n *ForwardedSection) ChildNodes() []Node { return n.Children }
+func (n *Link) ChildNodes() []Node             { return n.Children }
+func (n *Emphasis) ChildNodes() []Node         { return n.Children }
+
+func (n *Document) appendChild(child Node)         { n.Children = append(n.Children, child) }
+func (n *Paragraph) appendChild(child Node)        { n.Children = append(n.Children, child) }
+func (n *Quote) appendChild(child Node)            { n.Children = append(n.Children, child) }
+func (n *CollapsedQuote) appendChild(child Node)   { n.Children = append(n.Children, child) }
+func (n *ForwardedSection) appendChild(child Node) { n.Children = append(n.Children, child) }
+func (n *Link) appendChild(child Node)             { n.Children = append(n.Children, child) }
+func (n *Emphasis) appendChild(child Node)         { n.Children = append(n.Children, child) }
+
+// openNode returns the node opened by an open token.
+func openNode(token Token) (ParentNode, error) {
+	switch concreteToken := token.(type) {
+	case StartParagraphToken:
+		return &Paragraph{}, nil
+	case StartQuoteToken:
+		return &Quote{}, nil
+	case StartCollapsedQuoteToken:
+		return &CollapsedQuote{Attribution: concreteToken.Attribution, Lines: concreteToken.Lines}, nil
+	case StartForwardedToken:
+		return &ForwardedSection{Block: concreteToken.Block}, nil
+	case StartLinkToken:
+		return &Link{Href: concreteToken.Href}, nil
+	case StartEmphasisToken:
+		return &Emphasis{}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown open token %T", ErrUnbalancedTokens, token)
+	}
+}
+
+// closesNode returns whether a close token closes `node`.
+func closesNode(token Token, node ParentNode) bool {
+	switch token.(type) {
+	case EndParagraphToken:
+		_, ok := node.(*Paragraph)
+		return ok
+	case EndQuoteToken:
+		_, ok := node.(*Quote)
+		return ok
+	case EndCollapsedQuoteToken:
+		_, ok := node.(*CollapsedQuote)
+		return ok
+	case EndForwardedToken:
+		_, ok := node.(*ForwardedSection)
+		return ok
+	case EndLinkToken:
+		_, ok := node.(*Link)
+		return ok
+	case EndEmphasisToken:
+		_, ok := node.(*Emphasis)
+		return ok
+	default:
+		return false
+	}
+}
+
+// leafNode returns the node for a self-closing token.
+func leafNode(token Token) (Node, error) {
+	switch concreteToken := token.(type) {
+	case TextToken:
+		return &Text{Content: string(concreteToken)}, nil
+	case BlockToken:
+		return &BlockNode{Block: concreteToken.Block}, nil
+	case QuoteSourceToken:
+		return &QuoteSourceNode{Source: concreteToken.Source}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown token %T", ErrUnbalancedTokens, token)
+	}
+}
+
+// NewDocument builds a document tree from a token stream, finding the links
+// and emphasized text in it. This returns an error if the open and close
+// tokens in the stream don't match.
+func NewDocument(tokens []Token) (*Document, error) {
+	document := &Document{}
+
+	stack := []ParentNode{document}
+
+	for _, token := range tokens {
+		top := stack[len(stack)-1]
+
+		switch token.TagType() {
+		case TagTypeOpen:
+			node, err := openNode(token)
+			if err != nil {
+				return nil, err
+			}
+
+			top.appendChild(node)
+			stack = append(stack, node)
+		case TagTypeClose:
+			if len(stack) == 1 || !closesNode(token, top) {
+				return nil, fmt.Errorf("%w: unexpected %T", ErrUnbalancedTokens, token)
+			}
+
+			stack = stack[:len(stack)-1]
+		case TagTypeSelfClose:
+			// Text is split into inline nodes, unless it's already the text of
+			// a link.
+			if text, isText := token.(TextToken); isText {
+				if _, inLink := top.(*Link); !inLink {
+					for _, node := range inlineNodes(string(text)) {
+						top.appendChild(node)
+					}
+
+					continue
+				}
+			}
+
+			node, err := leafNode(token)
+			if err != nil {
+				return nil, err
+			}
+
+			top.appendChild(node)
+		}
+	}
+
+	if len(stack) > 1 {
+		return nil, fmt.Errorf("%w: unclosed %T", ErrUnbalancedTokens, stack[len(stack)-1])
+	}
+
+	return document, nil
+}
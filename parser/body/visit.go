@@ -0,0 +1,40 @@
+package body
+
+// Visitor visits the nodes in a document tree.
+type Visitor interface {
+	// Enter is called before the children of a node are visited. If it returns
+	// false, the children of the node are skipped.
+	Enter(node Node) bool
+
+	// Leave is called after the children of a node are visited, including when
+	// they were skipped.
+	Leave(node Node)
+}
+
+// Walk traverses the tree rooted at `node` depth-first, calling the methods of
+// `visitor` for each node.
+func Walk(node Node, visitor Visitor) {
+	if visitor.Enter(node) {
+		if parent, isParent := node.(ParentNode); isParent {
+			for _, child := range parent.ChildNodes() {
+				Walk(child, visitor)
+			}
+		}
+	}
+
+	visitor.Leave(node)
+}
+
+type inspector func(node Node) bool
+
+func (f inspector) Enter(node Node) bool {
+	return f(node)
+}
+
+func (inspector) Leave(Node) {}
+
+// Inspect traverses the tree rooted at `node` depth-first, calling `visit` for
+// each node. If `visit` returns false, the children of that node are skipped.
+func Inspect(node Node, visit func(node Node) bool) {
+	Walk(node, inspector(visit))
+}
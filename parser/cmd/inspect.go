@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/acearchive/yahoo-groups-reader/logger"
+	"github.com/acearchive/yahoo-groups-reader/parse"
+	"github.com/spf13/cobra"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var flagInspectJson bool
+
+func init() {
+	inspectCmd.Flags().BoolVar(&flagInspectJson, "json", false, "Print the output as JSON")
+
+	rootCmd.AddCommand(inspectCmd)
+}
+
+func writeSection(output io.Writer, title string, isFirst bool) {
+	if !isFirst {
+		fmt.Fprintln(output)
+	}
+
+	fmt.Fprintf(output, "== %s ==\n\n", title)
+}
+
+func writeInspection(output io.Writer, inspection parse.Inspection) {
+	writeSection(output, "Headers", true)
+
+	for _, header := range inspection.Headers {
+		fmt.Fprintf(output, "%s: %s\n", header.Name, header.Value)
+	}
+
+	writeSection(output, "Message", false)
+
+	fmt.Fprintf(output, "ID:     %s\n", inspection.Message.ID)
+	fmt.Fprintf(output, "Parent: %s\n", inspection.Message.Parent)
+	fmt.Fprintf(output, "User:   %s\n", inspection.Message.User)
+	fmt.Fprintf(output, "Flair:  %s\n", inspection.Message.Flair)
+	fmt.Fprintf(output, "Date:   %s\n", inspection.Message.Date)
+	fmt.Fprintf(output, "Title:  %s\n", inspection.Message.Title)
+
+	writeSection(output, "Body part", false)
+
+	partIndex := "(not multipart)"
+	if inspection.Part.PartIndex >= 0 {
+		partIndex = strconv.Itoa(inspection.Part.PartIndex)
+	}
+
+	charset := inspection.Part.Charset
+	if inspection.Part.IsDefaultCharset {
+		charset += " (default)"
+	}
+
+	fmt.Fprintf(output, "Media type:        %s\n", inspection.Part.MediaType)
+	fmt.Fprintf(output, "Part:              %s\n", partIndex)
+	fmt.Fprintf(output, "Charset:           %s\n", charset)
+	fmt.Fprintf(output, "Transfer encoding: %s\n", inspection.Part.TransferEncoding)
+
+	writeSection(output, "Lines", false)
+
+	for i, line := range inspection.Lines {
+		fmt.Fprintf(output, "%4d [%d] %s\n", i+1, line.QuoteDepth, line.Content)
+	}
+
+	writeSection(output, "Block matches", false)
+
+	for _, match := range inspection.BlockMatches {
+		name := match.Block
+		if match.Pattern != "" {
+			name = fmt.Sprintf("%s (%s)", match.Block, match.Pattern)
+		}
+
+		fmt.Fprintf(output, "%s in paragraph %d at %d-%d: %q\n", name, match.Paragraph, match.Start, match.End, match.Text)
+	}
+
+	writeSection(output, "Tokens", false)
+
+	for _, token := range inspection.Tokens {
+		indent := strings.Repeat("  ", token.Depth)
+
+		if token.Value == "" {
+			fmt.Fprintf(output, "%s%s\n", indent, token.Type)
+		} else {
+			fmt.Fprintf(output, "%s%s %q\n", indent, token.Type, token.Value)
+		}
+	}
+
+	writeSection(output, "HTML", false)
+
+	fmt.Fprint(output, inspection.Html)
+
+	writeSection(output, "Markdown", false)
+
+	fmt.Fprint(output, inspection.Markdown)
+}
+
+var inspectCmd = &cobra.Command{
+	Use:                   "inspect [options] email-path",
+	Short:                 "Show how a single `.eml` file is parsed",
+	Long:                  "Show each step of parsing a single `.eml` file, for debugging why a message renders the way it does.\n\nThis only parses the one message, so attribution time zones and links to\nquoted messages, which depend on the rest of the archive, aren't resolved.",
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !flagVerbose {
+			logger.Verbose.SetOutput(ioutil.Discard)
+		}
+
+		parseConfig, err := parseConfigFromFlags()
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		inspection, err := parse.InspectEmail(file, parseConfig)
+		if err != nil {
+			return err
+		}
+
+		if flagInspectJson {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+
+			return encoder.Encode(inspection)
+		}
+
+		writeInspection(os.Stdout, inspection)
+
+		return nil
+	},
+}
@@ -3,6 +3,8 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"github.com/acearchive/yahoo-groups-reader/block"
+	"github.com/acearchive/yahoo-groups-reader/body"
 	"github.com/acearchive/yahoo-groups-reader/logger"
 	"github.com/acearchive/yahoo-groups-reader/parse"
 	"github.com/acearchive/yahoo-groups-reader/render"
@@ -15,36 +17,74 @@ import (
 var ErrInvalidLinkInput = errors.New("malformed --link input")
 
 var (
-	flagPageSize    int
-	flagTitle       string
-	flagVerbose     bool
-	flagNoSearch    bool
-	flagOutput      string
-	flagBase        string
-	flagNoRepo      bool
-	flagLinks       []string
-	flagLocale      string
-	flagDescription string
+	flagPageSize          int
+	flagTitle             string
+	flagVerbose           bool
+	flagNoSearch          bool
+	flagOutput            string
+	flagBase              string
+	flagNoRepo            bool
+	flagLinks             []string
+	flagLocale            string
+	flagDescription       string
+	flagQuoteLines        int
+	flagQuoteDepth        int
+	flagAttributionLocale string
+	flagDateOrder         string
+	flagParentQuote       string
+	flagBlocks            string
+	flagPublishSource     bool
+	flagSourceHeaders     []string
+	flagPaginate          string
+	flagPageBytes         int
+	flagManifest          string
+	flagNoFeed            bool
+	flagRss               bool
+	flagTopicFeeds        bool
+	flagMemberFeeds       bool
+	flagNoSitemap         bool
 )
 
 const (
+	AutoDetectLocale  = "auto"
 	DefaultPageSize   = 25
+	DefaultPageBytes  = 512 * 1024
 	DefaultOutputPath = "../output"
 	DefaultBasePath   = "/"
 	DefaultGroupName  = "Yahoo Group"
 )
 
+// DisallowRobotsEnvVar is the environment variable the asset pipeline uses to
+// disallow crawling.
+const DisallowRobotsEnvVar = "DISALLOW_ROBOTS"
+
 func init() {
 	rootCmd.Flags().StringVarP(&flagTitle, "title", "t", DefaultGroupName, "The title of the group")
-	rootCmd.Flags().IntVar(&flagPageSize, "page-size", DefaultPageSize, "The maximum number of messages per page")
+	rootCmd.Flags().IntVar(&flagPageSize, "page-size", DefaultPageSize, "The number of messages per page, or the minimum with --paginate=topic")
+	rootCmd.Flags().StringVar(&flagPaginate, "paginate", render.PaginateFixed, "How to split messages into pages, either \"fixed\" for --page-size messages per page, \"month\" for a page per month, \"topic\" to never split a topic across pages, or \"size\" for pages of about --page-bytes")
+	rootCmd.Flags().IntVar(&flagPageBytes, "page-bytes", DefaultPageBytes, "The target size of each page in bytes with --paginate=size")
+	rootCmd.Flags().StringVar(&flagManifest, "manifest", "", "The path of a JSON file to keep message URLs stable between builds, which is created if it doesn't exist and must be outside the --output directory")
 	rootCmd.Flags().BoolVar(&flagNoSearch, "no-search", false, "Disable the search functionality in the generated site")
+	rootCmd.Flags().BoolVar(&flagNoFeed, "no-feed", false, "Don't publish an Atom feed of the most recent messages, which also requires an absolute --base")
+	rootCmd.Flags().BoolVar(&flagRss, "rss", false, "Also publish the feed of the most recent messages as RSS 2.0")
+	rootCmd.Flags().BoolVar(&flagTopicFeeds, "topic-feeds", false, "Publish an Atom feed for each topic")
+	rootCmd.Flags().BoolVar(&flagMemberFeeds, "member-feeds", false, "Publish an Atom feed for each member")
+	rootCmd.Flags().BoolVar(&flagNoSitemap, "no-sitemap", false, "Don't write a sitemap, which also isn't written when --base isn't absolute or the DISALLOW_ROBOTS environment variable is set")
 	rootCmd.Flags().BoolVar(&flagNoRepo, "no-repo", false, "Don't add a link to the GitHub repo in the generated site")
 	rootCmd.Flags().StringArrayVar(&flagLinks, "link", nil, "Add a link to the top of the page in the generated site")
-	rootCmd.Flags().StringVar(&flagLocale, "locale", "en_US", "The locale of the generated site")
+	rootCmd.PersistentFlags().StringVar(&flagLocale, "locale", "en_US", "The locale of the generated site")
+	rootCmd.PersistentFlags().StringVar(&flagAttributionLocale, "attribution-locale", "", "Comma-separated languages to recognize quote attributions in, or \"auto\" to try every supported language (defaults to the language of --locale)")
+	rootCmd.PersistentFlags().StringVar(&flagDateOrder, "date-order", "", "The order of the day and month to assume in ambiguous numeric dates in quote attributions, either \"mdy\" or \"dmy\" (defaults to the convention of each language)")
 	rootCmd.Flags().StringVar(&flagDescription, "description", "", "Override the default site description for search results and social previews")
+	rootCmd.Flags().IntVar(&flagQuoteLines, "collapse-quote-lines", 0, "Collapse quotes with at least this many lines (0 to disable)")
+	rootCmd.Flags().IntVar(&flagQuoteDepth, "collapse-quote-depth", 0, "Collapse quotes nested at least this deep (0 to disable)")
+	rootCmd.Flags().StringVar(&flagParentQuote, "parent-quote", string(body.ParentQuoteKeep), "What to do with a quote at the end of a reply which duplicates the parent message, either \"keep\", \"collapse\", or \"drop\"")
+	rootCmd.PersistentFlags().StringVar(&flagBlocks, "blocks", "", "The path of a JSON file defining custom blocks to recognize in messages")
+	rootCmd.Flags().BoolVar(&flagPublishSource, "publish-source", false, "Publish the original `.eml` file of each message with sensitive headers removed and addresses redacted from the rest; bodies are published as-is, including HTML parts and the headers of attached messages")
+	rootCmd.Flags().StringSliceVar(&flagSourceHeaders, "source-headers", render.DefaultSourceHeaders, "The headers to keep in published `.eml` files")
 	rootCmd.Flags().StringVarP(&flagOutput, "output", "o", DefaultOutputPath, "The directory to write the generated HTML to")
 	rootCmd.Flags().StringVarP(&flagBase, "base", "b", DefaultBasePath, "The base URL for the generated site")
-	rootCmd.Flags().BoolVarP(&flagVerbose, "verbose", "v", false, "Print verbose output.")
+	rootCmd.PersistentFlags().BoolVarP(&flagVerbose, "verbose", "v", false, "Print verbose output.")
 }
 
 func parseLinkInputs(inputs []string) ([]render.ExternalLinkConfig, error) {
@@ -66,6 +106,87 @@ func parseLinkInputs(inputs []string) ([]render.ExternalLinkConfig, error) {
 	return configs, nil
 }
 
+// parseAttributionLocales returns the locales to recognize attribution lines
+// in. English is always tried last, because some attribution lines are added
+// by Yahoo Groups itself regardless of the language of the group.
+func parseAttributionLocales(input, siteLocale string) ([]*block.Locale, error) {
+	if input == AutoDetectLocale {
+		return block.AllLocales(), nil
+	}
+
+	if input == "" {
+		locale, err := block.LocaleByName(siteLocale)
+		if errors.Is(err, block.ErrUnknownLocale) {
+			return []*block.Locale{block.DefaultLocale()}, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		input = locale.Name
+	}
+
+	var locales []*block.Locale
+
+	hasDefault := false
+
+	for _, name := range strings.Split(input, ",") {
+		locale, err := block.LocaleByName(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+
+		if locale == block.DefaultLocale() {
+			hasDefault = true
+		}
+
+		locales = append(locales, locale)
+	}
+
+	if !hasDefault {
+		locales = append(locales, block.DefaultLocale())
+	}
+
+	return locales, nil
+}
+
+// parseConfigFromFlags returns the config for parsing messages, which is shared
+// by every command.
+func parseConfigFromFlags() (parse.Config, error) {
+	attributionLocales, err := parseAttributionLocales(flagAttributionLocale, flagLocale)
+	if err != nil {
+		return parse.Config{}, err
+	}
+
+	dateOrder, err := block.ParseDateOrder(flagDateOrder)
+	if err != nil {
+		return parse.Config{}, err
+	}
+
+	var customBlocks []*block.CustomBlockDefinition
+
+	if flagBlocks != "" {
+		if customBlocks, err = block.LoadCustomBlocks(flagBlocks); err != nil {
+			return parse.Config{}, err
+		}
+	}
+
+	return parse.Config{
+		Blocks: block.Config{
+			Locales:      attributionLocales,
+			DateOrder:    dateOrder,
+			CustomBlocks: customBlocks,
+		},
+	}, nil
+}
+
+// isRobotsDisallowed returns whether the asset pipeline will generate a
+// `robots.txt` which disallows crawling, in which case there's no point in a
+// sitemap.
+func isRobotsDisallowed() bool {
+	_, isSet := os.LookupEnv(DisallowRobotsEnvVar)
+	return isSet
+}
+
 var rootCmd = &cobra.Command{
 	Use:                   "yahoo-groups-reader [options] archive-path",
 	Short:                 "Render an exported Yahoo Groups archive as HTML",
@@ -78,7 +199,12 @@ var rootCmd = &cobra.Command{
 			logger.Verbose.SetOutput(ioutil.Discard)
 		}
 
-		thread, err := parse.Directory(args[0])
+		parseConfig, err := parseConfigFromFlags()
+		if err != nil {
+			return err
+		}
+
+		thread, err := parse.Directory(args[0], parseConfig)
 		if err != nil {
 			return err
 		}
@@ -88,6 +214,16 @@ var rootCmd = &cobra.Command{
 			return err
 		}
 
+		parentQuoteMode, err := body.ParseParentQuoteMode(flagParentQuote)
+		if err != nil {
+			return err
+		}
+
+		paginationStrategy, err := render.ParsePaginationStrategy(flagPaginate, flagPageSize, flagPageBytes)
+		if err != nil {
+			return err
+		}
+
 		config := render.OutputConfig{
 			Title:             flagTitle,
 			CustomDescription: flagDescription,
@@ -97,6 +233,24 @@ var rootCmd = &cobra.Command{
 			AddRepoLink:       !flagNoRepo,
 			Links:             linkConfigs,
 			Locale:            flagLocale,
+			CollapseQuotes: body.CollapseConfig{
+				MinLines: flagQuoteLines,
+				MinDepth: flagQuoteDepth,
+			},
+			ParentQuote: parentQuoteMode,
+			Source: render.SourceConfig{
+				Publish:        flagPublishSource,
+				AllowedHeaders: flagSourceHeaders,
+			},
+			Pagination:   paginationStrategy,
+			ManifestPath: flagManifest,
+			Sitemap:      !flagNoSitemap && !isRobotsDisallowed(),
+			Feeds: render.FeedConfig{
+				Publish:   !flagNoFeed,
+				Rss:       flagRss,
+				PerTopic:  flagTopicFeeds,
+				PerMember: flagMemberFeeds,
+			},
 		}
 
 		if err := render.Execute(flagOutput, config, thread); err != nil {
@@ -3,11 +3,13 @@ package parse
 import (
 	"errors"
 	"fmt"
+	"github.com/acearchive/yahoo-groups-reader/block"
 	"github.com/acearchive/yahoo-groups-reader/body"
 	"github.com/acearchive/yahoo-groups-reader/logger"
 	"io"
 	"net/mail"
 	"regexp"
+	"strings"
 )
 
 type MimeHeader string
@@ -76,31 +78,46 @@ func flairFromEmail(email *mail.Message) string {
 	return ""
 }
 
-func bodyFromEmail(email *mail.Message) (MessageBody, error) {
+// Config determines how messages are parsed.
+type Config struct {
+	Blocks block.Config
+}
+
+func newTokenizer(config Config) body.Tokenizer {
+	return body.NewTokenizer(func() []block.Block {
+		return block.ConfiguredBlocks(config.Blocks)
+	})
+}
+
+func bodyFromEmail(email *mail.Message, config Config) (MessageBody, error) {
 	rawTextBody, err := DecodeMessageBody(email)
 	if err != nil {
 		return MessageBody{}, err
 	}
 
+	rawText, err := io.ReadAll(rawTextBody)
+	if err != nil {
+		return MessageBody{}, err
+	}
+
 	var messageBody MessageBody
 
-	tokenizer := body.NewDefaultTokenizer()
+	messageBody.Text = strings.ReplaceAll(string(rawText), "\r\n", "\n")
+
+	tokenizer := newTokenizer(config)
 
-	messageBody.Tokens, err = tokenizer.Tokenize(rawTextBody)
+	messageBody.Tokens, err = tokenizer.Tokenize(strings.NewReader(messageBody.Text))
 	if err != nil {
 		return MessageBody{}, err
 	}
 
-	messageBody.Html = body.Render(messageBody.Tokens)
-
 	return messageBody, nil
 }
 
-func Email(contents io.Reader) (Message, error) {
-	rawMessage, err := mail.ReadMessage(contents)
-	if err != nil {
-		return Message{}, fmt.Errorf("%w: %v", ErrMalformedEmail, err)
-	}
+// messageFromHeaders returns a message with the fields which come from the
+// headers of an email, leaving the body empty.
+func messageFromHeaders(rawMessage *mail.Message) (Message, error) {
+	var err error
 
 	message := Message{}
 
@@ -127,7 +144,24 @@ func Email(contents io.Reader) (Message, error) {
 		message.Title = &messageTitle
 	}
 
-	message.Body, err = bodyFromEmail(rawMessage)
+	return message, nil
+}
+
+func Email(contents io.Reader, config Config) (Message, error) {
+	rawMessage, err := mail.ReadMessage(contents)
+	if err != nil {
+		return Message{}, fmt.Errorf("%w: %v", ErrMalformedEmail, err)
+	}
+
+	message, err := messageFromHeaders(rawMessage)
+	if err != nil {
+		return Message{}, err
+	}
+
+	// The date of the message is used to disambiguate dates in the body.
+	config.Blocks.ReferenceTime = message.Date
+
+	message.Body, err = bodyFromEmail(rawMessage, config)
 	if err != nil {
 		return Message{}, fmt.Errorf("%w: %v", ErrMalformedEmail, err)
 	}
@@ -10,7 +10,7 @@ import (
 
 const EmailExtension = ".eml"
 
-func Directory(path string) (MessageThread, error) {
+func Directory(path string, config Config) (MessageThread, error) {
 	entries, err := os.ReadDir(path)
 	if err != nil {
 		return nil, err
@@ -36,7 +36,7 @@ func Directory(path string) (MessageThread, error) {
 			return nil, err
 		}
 
-		message, parseErr := Email(file)
+		message, parseErr := Email(file, config)
 
 		if err := file.Close(); err != nil {
 			return nil, err
@@ -49,8 +49,14 @@ func Directory(path string) (MessageThread, error) {
 			return nil, fmt.Errorf("%w: '%s'", parseErr, emailPath)
 		}
 
+		message.SourcePath = emailPath
 		thread[message.ID] = message
 	}
 
+	thread.resolveAttributionTimeZones()
+	thread.linkQuotedMessages()
+	thread.matchQuotedText()
+	thread.fillMissingParents()
+
 	return thread, nil
 }
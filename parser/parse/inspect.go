@@ -0,0 +1,224 @@
+package parse
+
+import (
+	"fmt"
+	"github.com/acearchive/yahoo-groups-reader/block"
+	"github.com/acearchive/yahoo-groups-reader/body"
+	"io"
+	"mime"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+)
+
+// InspectedHeader is a header of an email, with any encoded words decoded.
+type InspectedHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// InspectedPart describes the part of an email chosen as the message body and
+// how it was decoded.
+type InspectedPart struct {
+	MediaType        string `json:"mediaType"`
+	PartIndex        int    `json:"partIndex"`
+	Charset          string `json:"charset"`
+	IsDefaultCharset bool   `json:"isDefaultCharset"`
+	TransferEncoding string `json:"transferEncoding"`
+}
+
+type InspectedLine struct {
+	QuoteDepth int    `json:"quoteDepth"`
+	Content    string `json:"content"`
+}
+
+type InspectedToken struct {
+	Type    string `json:"type"`
+	TagType string `json:"tagType"`
+
+	// The nesting depth of the token in the token stream.
+	Depth int `json:"depth"`
+
+	// The text of a text token, or the kind of block of a block token.
+	Value string `json:"value,omitempty"`
+}
+
+type InspectedBlockMatch struct {
+	Block     string `json:"block"`
+	Pattern   string `json:"pattern,omitempty"`
+	Paragraph int    `json:"paragraph"`
+	Start     int    `json:"start"`
+	End       int    `json:"end"`
+	Text      string `json:"text"`
+}
+
+// InspectedMessage is the metadata parsed from the headers of an email.
+type InspectedMessage struct {
+	ID     string `json:"id"`
+	Parent string `json:"parent,omitempty"`
+	User   string `json:"user"`
+	Flair  string `json:"flair,omitempty"`
+	Date   string `json:"date"`
+	Title  string `json:"title,omitempty"`
+}
+
+// Inspection shows each step of parsing a single email, for debugging why a
+// message renders the way it does.
+type Inspection struct {
+	Headers      []InspectedHeader     `json:"headers"`
+	Message      InspectedMessage      `json:"message"`
+	Part         InspectedPart         `json:"part"`
+	Lines        []InspectedLine       `json:"lines"`
+	BlockMatches []InspectedBlockMatch `json:"blockMatches"`
+	Tokens       []InspectedToken      `json:"tokens"`
+	Html         string                `json:"html"`
+	Markdown     string                `json:"markdown"`
+}
+
+func inspectHeaders(header mail.Header) []InspectedHeader {
+	names := make([]string, 0, len(header))
+
+	for name := range header {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	decoder := new(mime.WordDecoder)
+
+	var headers []InspectedHeader
+
+	for _, name := range names {
+		for _, value := range header[name] {
+			if decoded, err := decoder.DecodeHeader(value); err == nil {
+				value = decoded
+			}
+
+			headers = append(headers, InspectedHeader{Name: name, Value: value})
+		}
+	}
+
+	return headers
+}
+
+func inspectTokens(tokens []body.Token) []InspectedToken {
+	inspected := make([]InspectedToken, 0, len(tokens))
+
+	depth := 0
+
+	for _, token := range tokens {
+		if token.TagType() == body.TagTypeClose {
+			depth--
+		}
+
+		inspectedToken := InspectedToken{
+			Type:    strings.TrimPrefix(fmt.Sprintf("%T", token), "body."),
+			TagType: string(token.TagType()),
+			Depth:   depth,
+		}
+
+		switch concreteToken := token.(type) {
+		case body.TextToken:
+			inspectedToken.Value = string(concreteToken)
+		case body.BlockToken:
+			inspectedToken.Value = block.BlockName(concreteToken.Block)
+		case body.StartForwardedToken:
+			inspectedToken.Value = block.BlockName(concreteToken.Block)
+		}
+
+		if token.TagType() == body.TagTypeOpen {
+			depth++
+		}
+
+		inspected = append(inspected, inspectedToken)
+	}
+
+	return inspected
+}
+
+// InspectEmail parses a single email and records each step along the way. This
+// only parses the email on its own, so attribution time zones and links to
+// quoted messages, which depend on the rest of the archive, aren't resolved.
+func InspectEmail(contents io.Reader, config Config) (Inspection, error) {
+	rawMessage, err := mail.ReadMessage(contents)
+	if err != nil {
+		return Inspection{}, fmt.Errorf("%w: %v", ErrMalformedEmail, err)
+	}
+
+	inspection := Inspection{Headers: inspectHeaders(rawMessage.Header)}
+
+	message, err := messageFromHeaders(rawMessage)
+	if err != nil {
+		return Inspection{}, err
+	}
+
+	inspection.Message = InspectedMessage{
+		ID:    string(message.ID),
+		User:  message.User,
+		Flair: message.Flair,
+		Date:  message.Date.Format(time.RFC3339),
+	}
+
+	if message.Parent != nil {
+		inspection.Message.Parent = string(*message.Parent)
+	}
+
+	if message.Title != nil {
+		inspection.Message.Title = *message.Title
+	}
+
+	decoded, err := DecodeMessageBodyPart(rawMessage)
+	if err != nil {
+		return Inspection{}, fmt.Errorf("%w: %v", ErrMalformedEmail, err)
+	}
+
+	inspection.Part = InspectedPart{
+		MediaType:        decoded.MediaType,
+		PartIndex:        decoded.PartIndex,
+		Charset:          decoded.Charset,
+		IsDefaultCharset: decoded.IsDefaultCharset,
+		TransferEncoding: decoded.TransferEncoding,
+	}
+
+	lines, err := body.ParseLines(decoded.Reader)
+	if err != nil {
+		return Inspection{}, fmt.Errorf("%w: %v", ErrMalformedEmail, err)
+	}
+
+	for _, line := range lines {
+		inspection.Lines = append(inspection.Lines, InspectedLine{QuoteDepth: line.QuoteDepth, Content: line.Content})
+	}
+
+	config.Blocks.ReferenceTime = message.Date
+
+	var matches []body.BlockMatch
+
+	tokenizer := newTokenizer(config)
+	tokenizer.TraceBlocks(func(match body.BlockMatch) {
+		matches = append(matches, match)
+	})
+
+	tokens := tokenizer.TokenizeLines(lines)
+
+	for _, match := range matches {
+		inspection.BlockMatches = append(inspection.BlockMatches, InspectedBlockMatch{
+			Block:     block.BlockName(match.Block),
+			Pattern:   match.Pattern,
+			Paragraph: match.Paragraph,
+			Start:     match.Start,
+			End:       match.End,
+			Text:      match.Text,
+		})
+	}
+
+	inspection.Tokens = inspectTokens(tokens)
+	inspection.Html = body.Render(tokens)
+
+	inspection.Markdown, err = body.RenderMarkdown(tokens)
+	if err != nil {
+		return Inspection{}, err
+	}
+
+	return inspection, nil
+}
@@ -0,0 +1,446 @@
+package parse
+
+import (
+	"fmt"
+	"github.com/acearchive/yahoo-groups-reader/block"
+	"github.com/acearchive/yahoo-groups-reader/body"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// The minimum similarity between two names for them to be considered the
+	// same person.
+	minNameSimilarity = 0.6
+
+	// How far apart the time of a quoted message and the time in the quote
+	// can be when both have a time zone.
+	quoteTimeTolerance = 10 * time.Minute
+
+	// How far apart the time of a quoted message and the time in the quote
+	// can be when the quote doesn't have a time zone, which is as far apart as
+	// two time zones can be.
+	quoteWallClockTolerance = 14 * time.Hour
+
+	// Time zone offsets are multiples of 15 minutes.
+	timeZoneOffsetGranularity = 15 * time.Minute
+
+	// How far the difference between the time of a quoted message and the
+	// time in a quote without a time zone can be from a whole time zone
+	// offset. This needs to be well under half of the granularity of time zone
+	// offsets, or every difference would be close enough to some offset.
+	quoteOffsetTolerance = 2 * time.Minute
+
+	// How much to favor the parent of the quoting message and a matching
+	// subject when picking between candidates.
+	parentBonus  = 0.2
+	subjectBonus = 0.3
+)
+
+// The prefixes mail clients add to the subject of a reply or a forwarded
+// message, in the languages we've seen them in.
+var (
+	replySubjectPrefixes   = []string{"re", "aw", "sv", "rv"}
+	forwardSubjectPrefixes = []string{"fw", "fwd", "wg", "tr", "enc"}
+)
+
+// The list tag at the start of a subject, like "[groupname]".
+const subjectTagRegexPart = `\[[^\]]*\]`
+
+// subjectPrefixRegexPart matches any of `prefixes`, which can have a count of
+// the replies, like "Re[2]:".
+func subjectPrefixRegexPart(prefixes []string) string {
+	return fmt.Sprintf(`(?:%s)\s*(?:\[\d+\])?\s*:`, strings.Join(prefixes, "|"))
+}
+
+var (
+	subjectPrefixRegex = regexp.MustCompile(fmt.Sprintf(`(?i)^\s*(?:%s\s*|%s\s*)+`, subjectPrefixRegexPart(append(replySubjectPrefixes, forwardSubjectPrefixes...)), subjectTagRegexPart))
+	replySubjectRegex  = regexp.MustCompile(fmt.Sprintf(`(?i)^\s*(?:%s\s*)*%s`, subjectTagRegexPart, subjectPrefixRegexPart(replySubjectPrefixes)))
+
+	// Layouts for the dates in quoted message headers, which are often written
+	// by mail clients in a localized, human-readable format instead of the
+	// format used in email headers.
+	quotedHeaderDateLayouts = []string{
+		"Monday, January 02, 2006 3:04 PM",
+		"Monday, January 2, 2006 3:04 PM",
+		"Monday, January 02, 2006 15:04",
+		"Monday, January 2, 2006 15:04",
+		"Mon, 2 Jan 2006 15:04:05 -0700",
+		"Mon, 2 Jan 2006 15:04:05",
+		"Mon, 2 Jan 2006 15:04",
+		"2 Jan 2006 15:04:05 -0700",
+		"January 2, 2006 3:04 PM",
+		"Jan 2, 2006 3:04 PM",
+		"1/2/2006 3:04:05 PM",
+		"1/2/2006 3:04 PM",
+		"1/2/06 3:04 PM",
+		"Mon, 2 Jan 2006",
+		"January 2, 2006",
+		"2 Jan 2006",
+	}
+)
+
+// quoteReference is what a quote tells us about the message it quotes.
+type quoteReference struct {
+	Name          string
+	Subject       string
+	Time          time.Time
+	HasDate       bool
+	HasTime       bool
+	HasTimeZone   bool
+	IsGuessedZone bool
+}
+
+// NormalizeSubject strips the reply and forward prefixes and the list tags
+// from the subject of a message so that messages in the same conversation
+// have the same subject.
+func NormalizeSubject(subject string) string {
+	return strings.ToLower(strings.Join(strings.Fields(subjectPrefixRegex.ReplaceAllString(subject, "")), " "))
+}
+
+// IsReplySubject returns whether the subject of a message starts with a reply
+// prefix, after any list tags.
+func IsReplySubject(subject string) bool {
+	return replySubjectRegex.MatchString(subject)
+}
+
+// levenshteinDistance returns the edit distance between two strings.
+func levenshteinDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = minInt(previous[j]+1, minInt(current[j-1]+1, previous[j-1]+cost))
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// nameWords returns the lowercase words in a name, or the local part of an
+// email address.
+func nameWords(name string) []string {
+	if at := strings.Index(name, "@"); at >= 0 {
+		name = name[:at]
+	}
+
+	return strings.FieldsFunc(strings.ToLower(name), func(char rune) bool {
+		return normalizeName(string(char)) == ""
+	})
+}
+
+// nameSimilarity returns how similar two names are, from 0 to 1. This tolerates
+// differences in case and punctuation, names which are a part of the other
+// name, like a first name and a full name, and small spelling differences.
+func nameSimilarity(a, b string) float64 {
+	normalizedA, normalizedB := normalizeName(strings.Join(nameWords(a), " ")), normalizeName(strings.Join(nameWords(b), " "))
+
+	if normalizedA == "" || normalizedB == "" {
+		return 0
+	}
+
+	if normalizedA == normalizedB {
+		return 1
+	}
+
+	const minPartialLen = 3
+
+	for _, wordA := range nameWords(a) {
+		for _, wordB := range nameWords(b) {
+			if len(wordA) >= minPartialLen && wordA == wordB {
+				return 0.8
+			}
+		}
+	}
+
+	runesA, runesB := []rune(normalizedA), []rune(normalizedB)
+	longest := len(runesA)
+	if len(runesB) > longest {
+		longest = len(runesB)
+	}
+
+	return 1 - float64(levenshteinDistance(runesA, runesB))/float64(longest)
+}
+
+func senderSimilarity(name string, message Message) float64 {
+	similarity := nameSimilarity(name, message.User)
+
+	if message.Flair != "" {
+		if flairSimilarity := nameSimilarity(name, message.Flair); flairSimilarity > similarity {
+			similarity = flairSimilarity
+		}
+	}
+
+	return similarity
+}
+
+func absDuration(duration time.Duration) time.Duration {
+	if duration < 0 {
+		return -duration
+	}
+
+	return duration
+}
+
+// timeSimilarity returns how closely the time in a quote matches the date of
+// a message, from 0 to 1, where 0 means it can't be the same message.
+func timeSimilarity(reference quoteReference, date time.Time) float64 {
+	switch {
+	case reference.HasTime && reference.HasTimeZone && !reference.IsGuessedZone:
+		if absDuration(reference.Time.Sub(date)) <= quoteTimeTolerance {
+			return 1
+		}
+
+		return 0
+	case reference.HasTime:
+		// Without a time zone, or with one which is only a guess, we can only
+		// check that the times are the same in some time zone.
+		difference := block.WallClockIn(reference.Time, time.UTC).Sub(block.WallClockIn(date, time.UTC))
+		if block.WallClockIn(reference.Time, date.Location()).Equal(date.Truncate(time.Minute)) {
+			return 1
+		}
+
+		if absDuration(difference) > quoteWallClockTolerance+quoteTimeTolerance {
+			return 0
+		}
+
+		offset := reference.Time.Sub(date)
+		if absDuration(offset-offset.Round(timeZoneOffsetGranularity)) <= quoteOffsetTolerance {
+			return 0.8
+		}
+
+		return 0
+	case reference.HasDate:
+		referenceYear, referenceMonth, referenceDay := reference.Time.Date()
+		year, month, day := date.Date()
+
+		if referenceYear == year && referenceMonth == month && referenceDay == day {
+			return 0.6
+		}
+
+		if absDuration(block.WallClockIn(reference.Time, time.UTC).Sub(block.WallClockIn(date, time.UTC))) <= quoteWallClockTolerance+24*time.Hour {
+			return 0.4
+		}
+
+		return 0
+	default:
+		return 0
+	}
+}
+
+// parseQuotedHeaderDate parses the date in a quoted message header.
+func parseQuotedHeaderDate(value string) (date time.Time, hasTime, hasTimeZone bool, ok bool) {
+	value = strings.TrimSpace(value)
+
+	if date, err := mail.ParseDate(value); err == nil {
+		return date, true, true, true
+	}
+
+	for _, layout := range quotedHeaderDateLayouts {
+		date, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+
+		return date, strings.Contains(layout, "15") || strings.Contains(layout, "3:04"), strings.Contains(layout, "-0700"), true
+	}
+
+	return time.Time{}, false, false, false
+}
+
+// quotedHeaderSender returns the name of the sender in the `From` field of a
+// quoted message header, or their email address if there is no name.
+func quotedHeaderSender(value string) string {
+	if address, err := mail.ParseAddress(value); err == nil {
+		if address.Name != "" {
+			return address.Name
+		}
+
+		return address.Address
+	}
+
+	if matches := addressRegex.FindStringSubmatch(value); matches != nil {
+		return matches[addressRegexNameIndex]
+	}
+
+	return strings.Trim(value, `"' `)
+}
+
+func referenceFromFields(fields []block.Field) (quoteReference, bool) {
+	from, hasFrom := block.FieldValue(fields, "From")
+	if !hasFrom {
+		return quoteReference{}, false
+	}
+
+	reference := quoteReference{Name: quotedHeaderSender(from)}
+
+	if subject, hasSubject := block.FieldValue(fields, "Subject"); hasSubject {
+		reference.Subject = subject
+	}
+
+	if dateValue, hasDate := block.FieldValue(fields, "Sent", "Date"); hasDate {
+		if date, hasTime, hasTimeZone, ok := parseQuotedHeaderDate(dateValue); ok {
+			reference.Time = date
+			reference.HasDate = true
+			reference.HasTime = hasTime
+			reference.HasTimeZone = hasTimeZone
+		}
+	}
+
+	return reference, true
+}
+
+func referenceFromBlock(linkable block.LinkableBlock) (quoteReference, bool) {
+	switch concrete := linkable.(type) {
+	case *block.AttributionBlock:
+		return quoteReference{
+			Name:          concrete.Name,
+			Time:          concrete.Time,
+			HasDate:       !concrete.Time.IsZero() && !concrete.DateAmbiguous,
+			HasTime:       concrete.HasTime && !concrete.DateAmbiguous,
+			HasTimeZone:   concrete.HasTimeZone,
+			IsGuessedZone: concrete.IsGuessedZone,
+		}, true
+	case *block.MessageHeaderBlock:
+		return referenceFromFields(concrete.Fields)
+	case *block.ForwardedBlock:
+		return referenceFromFields(concrete.Fields)
+	default:
+		return quoteReference{}, false
+	}
+}
+
+// quoteIndex finds the messages that quotes are quoting.
+type quoteIndex struct {
+	thread         MessageThread
+	messagesByDate []Message
+}
+
+func newQuoteIndex(thread MessageThread) quoteIndex {
+	messagesByDate, _ := thread.SortedByDate()
+
+	return quoteIndex{
+		thread:         thread,
+		messagesByDate: messagesByDate,
+	}
+}
+
+// candidatesAround returns the messages sent within `window` of `date`.
+func (q quoteIndex) candidatesAround(date time.Time, window time.Duration) []Message {
+	start := sort.Search(len(q.messagesByDate), func(i int) bool {
+		return !q.messagesByDate[i].Date.Before(date.Add(-window))
+	})
+
+	end := sort.Search(len(q.messagesByDate), func(i int) bool {
+		return q.messagesByDate[i].Date.After(date.Add(window))
+	})
+
+	return q.messagesByDate[start:end]
+}
+
+// find returns the message that a quote in `quoting` refers to, if it's in
+// the archive.
+func (q quoteIndex) find(reference quoteReference, quoting Message) (Message, bool) {
+	var parent *Message
+
+	if quoting.Parent != nil {
+		if message, parentExists := q.thread[*quoting.Parent]; parentExists {
+			parent = &message
+		}
+	}
+
+	// Without a date, the only message we can be confident a quote refers to
+	// is the parent of the quoting message.
+	if !reference.HasDate {
+		if parent != nil && senderSimilarity(reference.Name, *parent) >= minNameSimilarity {
+			return *parent, true
+		}
+
+		return Message{}, false
+	}
+
+	// The reference time is in UTC when it doesn't have a time zone, so we
+	// need to search a wide enough window to include every time zone.
+	candidates := q.candidatesAround(reference.Time, quoteWallClockTolerance+24*time.Hour)
+
+	var (
+		bestMatch Message
+		bestScore float64
+	)
+
+	for _, candidate := range candidates {
+		if !candidate.sentBefore(quoting) {
+			continue
+		}
+
+		nameScore := senderSimilarity(reference.Name, candidate)
+		if nameScore < minNameSimilarity {
+			continue
+		}
+
+		timeScore := timeSimilarity(reference, candidate.Date)
+		if timeScore == 0 {
+			continue
+		}
+
+		score := nameScore + timeScore
+
+		if parent != nil && candidate.ID == parent.ID {
+			score += parentBonus
+		}
+
+		if reference.Subject != "" && candidate.Title != nil && NormalizeSubject(reference.Subject) == NormalizeSubject(*candidate.Title) {
+			score += subjectBonus
+		}
+
+		if score > bestScore {
+			bestMatch, bestScore = candidate, score
+		}
+	}
+
+	return bestMatch, bestScore > 0
+}
+
+// linkQuotedMessages links attribution lines and quoted message headers to the
+// messages in the archive that they quote.
+func (t MessageThread) linkQuotedMessages() {
+	index := newQuoteIndex(t)
+
+	for _, message := range t {
+		for _, linkable := range body.LinkableBlocks(message.Body.Tokens) {
+			reference, ok := referenceFromBlock(linkable)
+			if !ok || reference.Name == "" {
+				continue
+			}
+
+			if quoted, found := index.find(reference, message); found {
+				linkable.Link().MessageID = string(quoted.ID)
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package parse
+
+import "testing"
+
+func TestSubjectPrefixes(t *testing.T) {
+	tests := []struct {
+		subject    string
+		normalized string
+		isReply    bool
+	}{
+		{subject: "Garden seeds", normalized: "garden seeds", isReply: false},
+		{subject: "Re: Garden seeds", normalized: "garden seeds", isReply: true},
+		{subject: "[gardeners] RE[2]: Garden  seeds", normalized: "garden seeds", isReply: true},
+		{subject: "AW: Garden seeds", normalized: "garden seeds", isReply: true},
+		{subject: "SV: Garden seeds", normalized: "garden seeds", isReply: true},
+		{subject: "RV: Garden seeds", normalized: "garden seeds", isReply: true},
+		{subject: "Fwd: Garden seeds", normalized: "garden seeds", isReply: false},
+		{subject: "WG: Re: Garden seeds", normalized: "garden seeds", isReply: false},
+		{subject: "Regarding garden seeds", normalized: "regarding garden seeds", isReply: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.subject, func(t *testing.T) {
+			if normalized := NormalizeSubject(test.subject); normalized != test.normalized {
+				t.Errorf("normalized to %q, want %q", normalized, test.normalized)
+			}
+
+			if isReply := IsReplySubject(test.subject); isReply != test.isReply {
+				t.Errorf("got IsReplySubject %v, want %v", isReply, test.isReply)
+			}
+		})
+	}
+}
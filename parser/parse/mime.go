@@ -24,46 +24,91 @@ const (
 
 var DefaultCharset = charmap.Windows1252
 
-func decodeCharset(body io.Reader, contentTypeParams map[string]string) io.Reader {
+// DecodedBody is the part of an email chosen as the message body, decoded
+// into UTF-8.
+type DecodedBody struct {
+	Reader io.Reader
+
+	// The media type of the chosen part.
+	MediaType string
+
+	// The index of the chosen part in a multipart email, or -1 if the email
+	// isn't multipart.
+	PartIndex int
+
+	// The charset the body was decoded from, and whether it was the default
+	// because the email didn't specify a charset we recognize.
+	Charset          string
+	IsDefaultCharset bool
+
+	TransferEncoding string
+}
+
+func decodeCharset(body io.Reader, contentTypeParams map[string]string) (reader io.Reader, charsetName string, isDefault bool) {
 	if charset, hasCharset := contentTypeParams[contentTypeParamCharset]; hasCharset {
 		encoding, err := ianaindex.MIME.Encoding(charset)
-		if err != nil {
-			return DefaultCharset.NewDecoder().Reader(body)
+		if err == nil && encoding != nil {
+			return encoding.NewDecoder().Reader(body), charset, false
 		}
-
-		return encoding.NewDecoder().Reader(body)
 	}
 
-	return DefaultCharset.NewDecoder().Reader(body)
+	defaultName, _ := ianaindex.MIME.Name(DefaultCharset)
+
+	return DefaultCharset.NewDecoder().Reader(body), defaultName, true
 }
 
 func DecodeMessageBody(email *mail.Message) (io.Reader, error) {
+	decoded, err := DecodeMessageBodyPart(email)
+	if err != nil {
+		return nil, err
+	}
+
+	return decoded.Reader, nil
+}
+
+// DecodeMessageBodyPart decodes the message body of an email like
+// `DecodeMessageBody`, and also returns which part of the email was chosen and
+// how it was decoded.
+func DecodeMessageBodyPart(email *mail.Message) (DecodedBody, error) {
+	decoded := DecodedBody{PartIndex: -1}
+
 	mediaType, contentTypeParams, err := mime.ParseMediaType(email.Header.Get(MimeHeaderContentType))
 
 	if err == nil && strings.HasPrefix(mediaType, contentTypePrefixMultipart) {
 		multipartReader := multipart.NewReader(email.Body, contentTypeParams[contentTypeParamBoundary])
-		for {
+		for partIndex := 0; ; partIndex++ {
 			part, err := multipartReader.NextPart()
 			if errors.Is(err, io.EOF) {
 				break
 			} else if err != nil {
-				return nil, err
+				return DecodedBody{}, err
 			}
 
 			partMediaType, partContentTypeParams, err := mime.ParseMediaType(part.Header.Get(MimeHeaderContentType))
 			if err != nil {
-				return nil, err
+				return DecodedBody{}, err
 			}
 
 			if partMediaType == contentTypePlainText {
-				return decodeCharset(part, partContentTypeParams), nil
+				decoded.MediaType = partMediaType
+				decoded.PartIndex = partIndex
+				decoded.TransferEncoding = part.Header.Get(MimeHeaderContentTransferEncoding)
+				decoded.Reader, decoded.Charset, decoded.IsDefaultCharset = decodeCharset(part, partContentTypeParams)
+
+				return decoded, nil
 			}
 		}
 	}
 
-	if email.Header.Get(MimeHeaderContentTransferEncoding) == quotedPrintable {
-		return decodeCharset(quotedprintable.NewReader(email.Body), contentTypeParams), nil
+	decoded.MediaType = mediaType
+	decoded.TransferEncoding = email.Header.Get(MimeHeaderContentTransferEncoding)
+
+	if decoded.TransferEncoding == quotedPrintable {
+		decoded.Reader, decoded.Charset, decoded.IsDefaultCharset = decodeCharset(quotedprintable.NewReader(email.Body), contentTypeParams)
+		return decoded, nil
 	}
 
-	return decodeCharset(email.Body, contentTypeParams), nil
+	decoded.Reader, decoded.Charset, decoded.IsDefaultCharset = decodeCharset(email.Body, contentTypeParams)
+
+	return decoded, nil
 }
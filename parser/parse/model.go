@@ -9,8 +9,9 @@ import (
 type MessageID string
 
 type MessageBody struct {
+	// The decoded plain text of the message as it was received.
+	Text   string
 	Tokens []body.Token
-	Html   string
 }
 
 type Message struct {
@@ -21,6 +22,21 @@ type Message struct {
 	Date   time.Time
 	Title  *string
 	Body   MessageBody
+
+	// The path of the `.eml` file the message was parsed from, if it was
+	// parsed from a file.
+	SourcePath string
+}
+
+// sentBefore returns whether `m` was sent before `other`. Messages sent at the
+// same time are ordered by ID so that two messages can never each be sent
+// before the other.
+func (m Message) sentBefore(other Message) bool {
+	if m.Date.Equal(other.Date) {
+		return m.ID < other.ID
+	}
+
+	return m.Date.Before(other.Date)
 }
 
 type MessageThread map[MessageID]Message
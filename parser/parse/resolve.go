@@ -0,0 +1,140 @@
+package parse
+
+import (
+	"github.com/acearchive/yahoo-groups-reader/block"
+	"github.com/acearchive/yahoo-groups-reader/body"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// How far apart the time in an attribution line and the date of the message
+// it quotes can be for them to be considered the same. Attribution lines
+// usually only have minute precision.
+const attributionTimeTolerance = time.Minute
+
+// normalizeName normalizes the name of a sender so that names which differ
+// only in case, punctuation, or whitespace compare equal.
+func normalizeName(name string) string {
+	var builder strings.Builder
+
+	for _, char := range strings.ToLower(name) {
+		if unicode.IsLetter(char) || unicode.IsDigit(char) {
+			builder.WriteRune(char)
+		}
+	}
+
+	return builder.String()
+}
+
+func attributionBlocks(tokens []body.Token) []*block.AttributionBlock {
+	var blocks []*block.AttributionBlock
+
+	for _, token := range tokens {
+		if blockToken, isBlock := token.(body.BlockToken); isBlock {
+			if attribution, isAttribution := blockToken.Block.(*block.AttributionBlock); isAttribution {
+				blocks = append(blocks, attribution)
+			}
+		}
+	}
+
+	return blocks
+}
+
+func (t MessageThread) messagesByUser() map[string][]Message {
+	messagesByUser := make(map[string][]Message)
+
+	for _, message := range t {
+		name := normalizeName(message.User)
+		messagesByUser[name] = append(messagesByUser[name], message)
+	}
+
+	return messagesByUser
+}
+
+func withinTolerance(a, b time.Time) bool {
+	difference := a.Sub(b)
+	return difference <= attributionTimeTolerance && difference >= -attributionTimeTolerance
+}
+
+// matchQuotedMessageZone looks for the message quoted by an attribution among
+// `candidates`, and returns the time zone the attribution time is in if it
+// finds it. Mail clients write attribution times either in the time zone of
+// the quoted message or in the time zone of the person quoting it, so we try
+// both.
+func matchQuotedMessageZone(attribution *block.AttributionBlock, quoting Message, candidates []Message) (*time.Location, bool) {
+	for _, candidate := range candidates {
+		if candidate.ID == quoting.ID {
+			continue
+		}
+
+		for _, location := range []*time.Location{candidate.Date.Location(), quoting.Date.Location()} {
+			if withinTolerance(block.WallClockIn(attribution.Time, location), candidate.Date) {
+				return location, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// typicalZone returns the time zone offset used most often by `messages`.
+func typicalZone(messages []Message) (*time.Location, bool) {
+	offsetCounts := make(map[int]int)
+	locationsByOffset := make(map[int]*time.Location)
+
+	for _, message := range messages {
+		_, offset := message.Date.Zone()
+		offsetCounts[offset]++
+		locationsByOffset[offset] = message.Date.Location()
+	}
+
+	bestOffset, bestCount := 0, 0
+
+	for offset, count := range offsetCounts {
+		// Break ties deterministically, since map iteration order is random.
+		if count > bestCount || (count == bestCount && offset < bestOffset) {
+			bestOffset, bestCount = offset, count
+		}
+	}
+
+	if bestCount == 0 {
+		return nil, false
+	}
+
+	return locationsByOffset[bestOffset], true
+}
+
+// resolveAttributionTimeZones resolves the time zones of attribution lines
+// which include a time but no time zone. We first look for the quoted message
+// in the archive, preferring the parent of the message, and otherwise use the
+// time zone the sender of the quoted message typically uses. Attribution times
+// which can't be resolved are left without a time zone.
+func (t MessageThread) resolveAttributionTimeZones() {
+	messagesByUser := t.messagesByUser()
+
+	for _, message := range t {
+		for _, attribution := range attributionBlocks(message.Body.Tokens) {
+			if !attribution.HasTime || attribution.HasTimeZone || attribution.Time.IsZero() {
+				continue
+			}
+
+			var candidates []Message
+
+			if message.Parent != nil {
+				if parent, parentExists := t[*message.Parent]; parentExists {
+					candidates = append(candidates, parent)
+				}
+			}
+
+			senderMessages := messagesByUser[normalizeName(attribution.Name)]
+			candidates = append(candidates, senderMessages...)
+
+			if location, ok := matchQuotedMessageZone(attribution, message, candidates); ok {
+				attribution.SetTimeZone(location)
+			} else if location, ok := typicalZone(senderMessages); ok {
+				attribution.GuessTimeZone(location)
+			}
+		}
+	}
+}
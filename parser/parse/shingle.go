@@ -0,0 +1,357 @@
+package parse
+
+import (
+	"github.com/acearchive/yahoo-groups-reader/block"
+	"github.com/acearchive/yahoo-groups-reader/body"
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// The number of consecutive words in each shingle.
+	shingleSize = 5
+
+	// Shingles are sampled by winnowing, which picks the smallest hash in each
+	// window of this many consecutive shingles. This keeps the index small
+	// enough for large archives while guaranteeing that any run of text at
+	// least `shingleSize + winnowWindow - 1` words long shares a shingle with
+	// every message it appears in.
+	winnowWindow = 4
+
+	// Shingles which appear in more messages than this are boilerplate, like
+	// signatures and mailing list footers, and don't tell us anything about
+	// where a quote came from.
+	maxShingleMessages = 32
+
+	// The minimum number of shingles a quote must share with a message, and
+	// the minimum fraction of the shingles in the quote that must be shared,
+	// for the quote to be considered to come from that message.
+	minSharedShingles     = 2
+	minSharedShingleRatio = 0.5
+)
+
+func textWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(char rune) bool {
+		return !unicode.IsLetter(char) && !unicode.IsDigit(char)
+	})
+}
+
+func shingleHashes(words []string) []uint64 {
+	if len(words) <= shingleSize {
+		hash := fnv.New64a()
+		hash.Write([]byte(strings.Join(words, " ")))
+
+		return []uint64{hash.Sum64()}
+	}
+
+	hashes := make([]uint64, 0, len(words)-shingleSize+1)
+
+	for start := 0; start+shingleSize <= len(words); start++ {
+		hash := fnv.New64a()
+		hash.Write([]byte(strings.Join(words[start:start+shingleSize], " ")))
+		hashes = append(hashes, hash.Sum64())
+	}
+
+	return hashes
+}
+
+// sampledShingles returns the hashes of the shingles in `text` which are
+// selected by winnowing, without duplicates. Text too short for a single
+// shingle is hashed as a whole so that short messages can still be matched.
+func sampledShingles(text string) []uint64 {
+	words := textWords(text)
+	if len(words) == 0 {
+		return nil
+	}
+
+	hashes := shingleHashes(words)
+
+	windowSize := winnowWindow
+	if len(hashes) < windowSize {
+		windowSize = len(hashes)
+	}
+
+	seen := make(map[uint64]struct{})
+
+	var shingles []uint64
+
+	for windowStart := 0; windowStart+windowSize <= len(hashes); windowStart++ {
+		minimum := hashes[windowStart]
+
+		for _, hash := range hashes[windowStart+1 : windowStart+windowSize] {
+			if hash < minimum {
+				minimum = hash
+			}
+		}
+
+		if _, isDuplicate := seen[minimum]; isDuplicate {
+			continue
+		}
+
+		seen[minimum] = struct{}{}
+		shingles = append(shingles, minimum)
+	}
+
+	return shingles
+}
+
+// ownText returns the text of a message body which was written by the sender,
+// excluding quotes and forwarded messages.
+func ownText(tokens []body.Token) string {
+	var builder strings.Builder
+
+	quoteDepth, forwardedDepth := 0, 0
+
+	for _, token := range tokens {
+		switch concreteToken := token.(type) {
+		case body.StartQuoteToken:
+			quoteDepth++
+		case body.EndQuoteToken:
+			quoteDepth--
+		case body.StartForwardedToken:
+			forwardedDepth++
+		case body.EndForwardedToken:
+			forwardedDepth--
+		case body.TextToken:
+			if quoteDepth == 0 && forwardedDepth == 0 {
+				builder.WriteString(string(concreteToken))
+				builder.WriteString("\n")
+			}
+		}
+	}
+
+	return builder.String()
+}
+
+// quotedSection is a top-level quote in a message body.
+type quotedSection struct {
+	// The index of the `StartQuoteToken` which opens the quote.
+	StartIndex int
+
+	// The text of the quote, excluding any quotes nested inside it, since
+	// those come from a different message.
+	Text string
+}
+
+func quotedSections(tokens []body.Token) []quotedSection {
+	var (
+		sections []quotedSection
+		builder  strings.Builder
+	)
+
+	quoteDepth, startIndex := 0, 0
+
+	for i, token := range tokens {
+		switch concreteToken := token.(type) {
+		case body.StartQuoteToken:
+			quoteDepth++
+			if quoteDepth == 1 {
+				startIndex = i
+				builder.Reset()
+			}
+		case body.EndQuoteToken:
+			quoteDepth--
+			if quoteDepth == 0 {
+				sections = append(sections, quotedSection{StartIndex: startIndex, Text: builder.String()})
+			}
+		case body.TextToken:
+			if quoteDepth == 1 {
+				builder.WriteString(string(concreteToken))
+				builder.WriteString("\n")
+			}
+		}
+	}
+
+	return sections
+}
+
+// shingleIndex is an index of the shingles in the text of each message, which
+// we use to find the message a quote came from.
+type shingleIndex struct {
+	messagesByDate []Message
+	postings       map[uint64][]int
+}
+
+func newShingleIndex(messagesByDate []Message) shingleIndex {
+	index := shingleIndex{
+		messagesByDate: messagesByDate,
+		postings:       make(map[uint64][]int),
+	}
+
+	for messageIndex, message := range messagesByDate {
+		for _, shingle := range sampledShingles(ownText(message.Body.Tokens)) {
+			index.postings[shingle] = append(index.postings[shingle], messageIndex)
+		}
+	}
+
+	return index
+}
+
+// find returns the message sent before `quoting` which `text` most likely
+// came from.
+func (s shingleIndex) find(text string, quoting Message) (Message, bool) {
+	shingles := sampledShingles(text)
+	if len(shingles) == 0 {
+		return Message{}, false
+	}
+
+	sharedCounts := make(map[int]int)
+
+	for _, shingle := range shingles {
+		postings := s.postings[shingle]
+		if len(postings) > maxShingleMessages {
+			continue
+		}
+
+		for _, messageIndex := range postings {
+			candidate := s.messagesByDate[messageIndex]
+			if !candidate.sentBefore(quoting) {
+				continue
+			}
+
+			sharedCounts[messageIndex]++
+		}
+	}
+
+	bestIndex, bestCount := -1, 0
+
+	for messageIndex, count := range sharedCounts {
+		// When the same text appears in several messages, prefer the most recent
+		// one, which is the one most likely to have been replied to.
+		if count > bestCount || (count == bestCount && messageIndex > bestIndex) {
+			bestIndex, bestCount = messageIndex, count
+		}
+	}
+
+	minShared := minSharedShingles
+	if len(shingles) < minShared {
+		minShared = len(shingles)
+	}
+
+	if bestIndex < 0 || bestCount < minShared || float64(bestCount) < minSharedShingleRatio*float64(len(shingles)) {
+		return Message{}, false
+	}
+
+	return s.messagesByDate[bestIndex], true
+}
+
+// precedingLink returns the link of the attribution line or quoted message
+// header directly before the token at `index`, if there is one.
+func precedingLink(tokens []body.Token, index int) (*block.QuoteLink, bool) {
+	if index == 0 {
+		return nil, false
+	}
+
+	if blockToken, isBlock := tokens[index-1].(body.BlockToken); isBlock {
+		if linkable, isLinkable := blockToken.Block.(block.LinkableBlock); isLinkable {
+			return linkable.Link(), true
+		}
+	}
+
+	return nil, false
+}
+
+// matchQuotedText finds the message that each top-level quote came from by
+// matching its text against the text of earlier messages. When a quote has an
+// attribution line which couldn't be linked, the attribution line is linked to
+// the message instead. Otherwise, a link to the message is added before the
+// quote.
+func (t MessageThread) matchQuotedText() {
+	messagesByDate, _ := t.SortedByDate()
+	index := newShingleIndex(messagesByDate)
+
+	for _, message := range messagesByDate {
+		sections := quotedSections(message.Body.Tokens)
+		if len(sections) == 0 {
+			continue
+		}
+
+		tokens := make([]body.Token, 0, len(message.Body.Tokens)+len(sections))
+		previousIndex := 0
+
+		for _, section := range sections {
+			link, hasLink := precedingLink(message.Body.Tokens, section.StartIndex)
+			if hasLink && link.MessageID != "" {
+				continue
+			}
+
+			source, found := index.find(section.Text, message)
+			if !found {
+				continue
+			}
+
+			if hasLink {
+				link.MessageID = string(source.ID)
+				continue
+			}
+
+			tokens = append(tokens, message.Body.Tokens[previousIndex:section.StartIndex]...)
+			tokens = append(tokens, body.QuoteSourceToken{
+				Source: &body.QuoteSource{QuoteLink: block.QuoteLink{MessageID: string(source.ID)}},
+			})
+			previousIndex = section.StartIndex
+		}
+
+		message.Body.Tokens = append(tokens, message.Body.Tokens[previousIndex:]...)
+		t[message.ID] = message
+	}
+}
+
+// quotedMessageIDs returns the IDs of the messages quoted in a message body
+// which were found in the archive, in the order they're quoted. Forwarded
+// messages and anything quoted inside them are skipped.
+func quotedMessageIDs(tokens []body.Token) []MessageID {
+	var ids []MessageID
+
+	forwardedDepth := 0
+
+	for _, token := range tokens {
+		var link *block.QuoteLink
+
+		switch concreteToken := token.(type) {
+		case body.StartForwardedToken:
+			forwardedDepth++
+		case body.EndForwardedToken:
+			forwardedDepth--
+		case body.QuoteSourceToken:
+			link = &concreteToken.Source.QuoteLink
+		case body.BlockToken:
+			if _, isForwarded := concreteToken.Block.(*block.ForwardedBlock); isForwarded {
+				continue
+			}
+
+			if linkable, isLinkable := concreteToken.Block.(block.LinkableBlock); isLinkable {
+				link = linkable.Link()
+			}
+		}
+
+		if forwardedDepth > 0 {
+			continue
+		}
+
+		if link != nil && link.MessageID != "" {
+			ids = append(ids, MessageID(link.MessageID))
+		}
+	}
+
+	return ids
+}
+
+// fillMissingParents sets the parent of messages which don't have an
+// `In-Reply-To` header to the first message in the archive that they quote.
+// Forwarded messages aren't considered, since forwarding a message isn't
+// replying to it.
+func (t MessageThread) fillMissingParents() {
+	for id, message := range t {
+		if message.Parent != nil {
+			continue
+		}
+
+		if quotedIDs := quotedMessageIDs(message.Body.Tokens); len(quotedIDs) > 0 {
+			parentID := quotedIDs[0]
+			message.Parent = &parentID
+			t[id] = message
+		}
+	}
+}
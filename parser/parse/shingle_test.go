@@ -0,0 +1,113 @@
+package parse
+
+import (
+	"github.com/acearchive/yahoo-groups-reader/block"
+	"github.com/acearchive/yahoo-groups-reader/body"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	quotedParagraph = "The meeting has been moved to the library on Thursday evening because the hall is being painted"
+	otherParagraph  = "Does anyone know a good place to buy seeds for a vegetable garden this late in the season"
+)
+
+func textMessage(id MessageID, date time.Time, text string) Message {
+	return Message{
+		ID:   id,
+		Date: date,
+		Body: MessageBody{
+			Tokens: []body.Token{
+				body.StartParagraphToken{},
+				body.TextToken(text),
+				body.EndParagraphToken{},
+			},
+		},
+	}
+}
+
+func TestSampledShinglesSharedByLongRuns(t *testing.T) {
+	words := textWords(quotedParagraph)
+	run := strings.Join(words[3:3+shingleSize+winnowWindow-1], " ")
+
+	runShingles := make(map[uint64]struct{})
+	for _, shingle := range sampledShingles(run) {
+		runShingles[shingle] = struct{}{}
+	}
+
+	for _, shingle := range sampledShingles(quotedParagraph) {
+		if _, shared := runShingles[shingle]; shared {
+			return
+		}
+	}
+
+	t.Errorf("a run of %d words shares no sampled shingle with the text it came from", shingleSize+winnowWindow-1)
+}
+
+func TestShingleIndexFind(t *testing.T) {
+	start := time.Date(2003, time.January, 6, 12, 0, 0, 0, time.UTC)
+
+	messagesByDate := []Message{
+		textMessage("a", start, quotedParagraph),
+		textMessage("b", start.Add(time.Hour), otherParagraph),
+	}
+
+	index := newShingleIndex(messagesByDate)
+
+	quoting := textMessage("c", start.Add(2*time.Hour), "I'll be there.")
+
+	source, found := index.find(quotedParagraph, quoting)
+	if !found || source.ID != "a" {
+		t.Errorf("expected the quote to come from message a, got %q (found: %v)", source.ID, found)
+	}
+
+	if _, found := index.find("See you all there.", quoting); found {
+		t.Error("expected unrelated text not to match any message")
+	}
+
+	earlier := textMessage("c", start.Add(-time.Hour), "I'll be there.")
+
+	if _, found := index.find(quotedParagraph, earlier); found {
+		t.Error("expected a quote not to come from a message sent after the quoting message")
+	}
+}
+
+func TestShingleIndexFindSameTime(t *testing.T) {
+	date := time.Date(2003, time.January, 6, 12, 0, 0, 0, time.UTC)
+
+	first := textMessage("a", date, quotedParagraph)
+	second := textMessage("b", date, quotedParagraph)
+
+	index := newShingleIndex([]Message{first, second})
+
+	source, firstFound := index.find(quotedParagraph, first)
+	_, secondFound := index.find(quotedParagraph, second)
+
+	if firstFound && secondFound {
+		t.Errorf("messages sent at the same time were each found to quote the other (first quotes %q)", source.ID)
+	}
+
+	if !secondFound {
+		t.Error("expected the message with the later ID to quote the one with the earlier ID")
+	}
+}
+
+func TestQuotedMessageIDsSkipsForwarded(t *testing.T) {
+	header := func(id string) *block.MessageHeaderBlock {
+		return &block.MessageHeaderBlock{QuoteLink: block.QuoteLink{MessageID: id}}
+	}
+
+	tokens := []body.Token{
+		body.StartForwardedToken{Block: &block.ForwardedBlock{QuoteLink: block.QuoteLink{MessageID: "forwarded"}}},
+		body.BlockToken{Block: header("inside")},
+		body.EndForwardedToken{},
+		body.BlockToken{Block: &block.ForwardedBlock{QuoteLink: block.QuoteLink{MessageID: "banner"}}},
+		body.BlockToken{Block: header("replied")},
+	}
+
+	ids := quotedMessageIDs(tokens)
+	if len(ids) != 1 || ids[0] != "replied" {
+		t.Errorf("expected only the message outside the forwarded section, got %v", ids)
+	}
+}
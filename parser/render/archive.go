@@ -0,0 +1,264 @@
+package render
+
+import (
+	"fmt"
+	"time"
+)
+
+const archiveIndexDirName = "archive"
+
+// The number of levels to shade the months in the calendar by, depending on
+// how many messages were sent that month. Months without any messages are
+// always level 0.
+const archiveVolumeLevels = 4
+
+// PeriodRef is a link to the archive page of a year or a month.
+type PeriodRef struct {
+	Label string
+	Path  PagePath
+}
+
+type MonthArchiveArgs struct {
+	Year  int
+	Month int
+	Name  string
+	Path  PagePath
+	Count int
+
+	// How many messages were sent this month relative to the busiest month in
+	// the archive, from 0 to `archiveVolumeLevels`.
+	Level int
+}
+
+type YearArchiveArgs struct {
+	Year   int
+	Path   PagePath
+	Count  int
+	Months []MonthArchiveArgs
+}
+
+type ArchiveIndexArgs struct {
+	SiteArgs
+	Meta       PageMetaArgs
+	MonthNames []string
+	Years      []YearArchiveArgs
+}
+
+type YearPageArgs struct {
+	SiteArgs
+	Meta          PageMetaArgs
+	Year          YearArchiveArgs
+	TopicsStarted []TopicSummaryArgs
+	Prev          *PeriodRef
+	Next          *PeriodRef
+}
+
+type MonthPageArgs struct {
+	SiteArgs
+	Meta     PageMetaArgs
+	Month    MonthArchiveArgs
+	Messages []MessageRef
+	Prev     *PeriodRef
+	Next     *PeriodRef
+}
+
+func archiveIndexPath() PagePath {
+	return PagePath(fmt.Sprintf("/%s/", archiveIndexDirName))
+}
+
+func yearPath(year int) PagePath {
+	return PagePath(fmt.Sprintf("/%s/%04d/", archiveIndexDirName, year))
+}
+
+func monthPath(year, month int) PagePath {
+	return PagePath(fmt.Sprintf("/%s/%04d/%02d/", archiveIndexDirName, year, month))
+}
+
+func monthName(year, month int) string {
+	return fmt.Sprintf("%s %d", time.Month(month), year)
+}
+
+func shortMonthNames() []string {
+	names := make([]string, 12)
+
+	for i := range names {
+		names[i] = time.Month(i + 1).String()[:3]
+	}
+
+	return names
+}
+
+func volumeLevel(count, maxCount int) int {
+	if count == 0 || maxCount == 0 {
+		return 0
+	}
+
+	// Round up so that any month with messages is shaded at least a little.
+	return (count*archiveVolumeLevels + maxCount - 1) / maxCount
+}
+
+func periodDescription(count int, period, siteTitle string) string {
+	if count == 1 {
+		return fmt.Sprintf("1 message sent in %s in %s.", period, siteTitle)
+	}
+
+	return fmt.Sprintf("%d messages sent in %s in %s.", count, period, siteTitle)
+}
+
+// buildArchiveArgs groups the messages by the year and month they were sent,
+// in the time zone they were sent in, since that's the date shown on each
+// message.
+func buildArchiveArgs(messages []MessageArgs, topics []TopicPageArgs, config OutputConfig) (ArchiveIndexArgs, []YearPageArgs, []MonthPageArgs) {
+	site := config.siteArgs()
+
+	if len(messages) == 0 {
+		return ArchiveIndexArgs{SiteArgs: site, Meta: archiveIndexMeta(site, config), MonthNames: shortMonthNames()}, nil, nil
+	}
+
+	firstYear, lastYear := messages[0].date.Year(), messages[0].date.Year()
+
+	for _, message := range messages {
+		if year := message.date.Year(); year < firstYear {
+			firstYear = year
+		} else if year > lastYear {
+			lastYear = year
+		}
+	}
+
+	years := make([]YearArchiveArgs, lastYear-firstYear+1)
+	messagesByMonth := make(map[PagePath][]MessageRef)
+
+	for yearIndex := range years {
+		year := firstYear + yearIndex
+
+		years[yearIndex] = YearArchiveArgs{Year: year, Path: yearPath(year), Months: make([]MonthArchiveArgs, 12)}
+
+		for monthIndex := range years[yearIndex].Months {
+			years[yearIndex].Months[monthIndex] = MonthArchiveArgs{
+				Year:  year,
+				Month: monthIndex + 1,
+				Name:  monthName(year, monthIndex+1),
+				Path:  monthPath(year, monthIndex+1),
+			}
+		}
+	}
+
+	maxMonthCount := 0
+
+	for _, message := range messages {
+		year := &years[message.date.Year()-firstYear]
+		month := &year.Months[message.date.Month()-1]
+
+		year.Count++
+		month.Count++
+
+		if month.Count > maxMonthCount {
+			maxMonthCount = month.Count
+		}
+
+		messagesByMonth[month.Path] = append(messagesByMonth[month.Path], *messageRef(message))
+	}
+
+	var (
+		activeYears  []YearArchiveArgs
+		activeMonths []MonthArchiveArgs
+	)
+
+	for yearIndex := range years {
+		for monthIndex := range years[yearIndex].Months {
+			month := &years[yearIndex].Months[monthIndex]
+			month.Level = volumeLevel(month.Count, maxMonthCount)
+
+			if month.Count > 0 {
+				activeMonths = append(activeMonths, *month)
+			}
+		}
+
+		if years[yearIndex].Count > 0 {
+			activeYears = append(activeYears, years[yearIndex])
+		}
+	}
+
+	topicsByYear := make(map[int][]TopicSummaryArgs)
+
+	for _, topic := range topics {
+		year := topic.Messages[0].date.Year()
+		topicsByYear[year] = append(topicsByYear[year], topic.Topic)
+	}
+
+	yearPages := make([]YearPageArgs, len(activeYears))
+
+	for yearIndex, year := range activeYears {
+		yearLabel := fmt.Sprintf("%d", year.Year)
+
+		yearPages[yearIndex] = YearPageArgs{
+			SiteArgs: site,
+			Meta: PageMetaArgs{
+				Title:       fmt.Sprintf("%s - %s", yearLabel, site.Title),
+				Description: periodDescription(year.Count, yearLabel, site.Title),
+				Canonical:   canonicalUrl(config.BaseUrl, year.Path),
+				Type:        "website",
+			},
+			Year:          year,
+			TopicsStarted: topicsByYear[year.Year],
+		}
+
+		if yearIndex > 0 {
+			prev := activeYears[yearIndex-1]
+			yearPages[yearIndex].Prev = &PeriodRef{Label: fmt.Sprintf("%d", prev.Year), Path: prev.Path}
+			yearPages[yearIndex].Meta.Prev = &yearPages[yearIndex].Prev.Path
+		}
+
+		if yearIndex < len(activeYears)-1 {
+			next := activeYears[yearIndex+1]
+			yearPages[yearIndex].Next = &PeriodRef{Label: fmt.Sprintf("%d", next.Year), Path: next.Path}
+			yearPages[yearIndex].Meta.Next = &yearPages[yearIndex].Next.Path
+		}
+	}
+
+	monthPages := make([]MonthPageArgs, len(activeMonths))
+
+	for monthIndex, month := range activeMonths {
+		monthPages[monthIndex] = MonthPageArgs{
+			SiteArgs: site,
+			Meta: PageMetaArgs{
+				Title:       fmt.Sprintf("%s - %s", month.Name, site.Title),
+				Description: periodDescription(month.Count, month.Name, site.Title),
+				Canonical:   canonicalUrl(config.BaseUrl, month.Path),
+				Type:        "website",
+			},
+			Month:    month,
+			Messages: messagesByMonth[month.Path],
+		}
+
+		if monthIndex > 0 {
+			prev := activeMonths[monthIndex-1]
+			monthPages[monthIndex].Prev = &PeriodRef{Label: prev.Name, Path: prev.Path}
+			monthPages[monthIndex].Meta.Prev = &monthPages[monthIndex].Prev.Path
+		}
+
+		if monthIndex < len(activeMonths)-1 {
+			next := activeMonths[monthIndex+1]
+			monthPages[monthIndex].Next = &PeriodRef{Label: next.Name, Path: next.Path}
+			monthPages[monthIndex].Meta.Next = &monthPages[monthIndex].Next.Path
+		}
+	}
+
+	index := ArchiveIndexArgs{
+		SiteArgs:   site,
+		Meta:       archiveIndexMeta(site, config),
+		MonthNames: shortMonthNames(),
+		Years:      activeYears,
+	}
+
+	return index, yearPages, monthPages
+}
+
+func archiveIndexMeta(site SiteArgs, config OutputConfig) PageMetaArgs {
+	return PageMetaArgs{
+		Title:       fmt.Sprintf("Archive - %s", site.Title),
+		Description: site.Description,
+		Canonical:   canonicalUrl(config.BaseUrl, archiveIndexPath()),
+		Type:        "website",
+	}
+}
@@ -0,0 +1,54 @@
+package render
+
+import (
+	"fmt"
+	"github.com/acearchive/yahoo-groups-reader/body"
+	"github.com/acearchive/yahoo-groups-reader/parse"
+)
+
+// messagePermalink returns the path of the standalone page of a message.
+func messagePermalink(index int) PagePath {
+	return PagePath(fmt.Sprintf("/%s/%d/", messageDirName, index))
+}
+
+// The placeholder for the number of a message in `messagePermalinkPattern`.
+const messageNumberPlaceholder = "{number}"
+
+// messagePermalinkPattern returns the path of the standalone page of a message
+// with a placeholder for its number, for scripts which link to messages.
+func messagePermalinkPattern() string {
+	return fmt.Sprintf("/%s/%s/", messageDirName, messageNumberPlaceholder)
+}
+
+// linkQuotedMessages sets the links on the blocks in each message which quote
+// another message in the archive, now that we know the number of each message.
+func linkQuotedMessages(messagesByDate []parse.Message, numbers map[parse.MessageID]int) {
+	for _, message := range messagesByDate {
+		for _, linkable := range body.LinkableBlocks(message.Body.Tokens) {
+			link := linkable.Link()
+
+			if quotedNumber, isArchived := numbers[parse.MessageID(link.MessageID)]; isArchived {
+				link.Href = string(messagePermalink(quotedNumber))
+			}
+		}
+
+		for _, source := range body.QuoteSources(message.Body.Tokens) {
+			if quotedNumber, isArchived := numbers[parse.MessageID(source.MessageID)]; isArchived {
+				source.Href = string(messagePermalink(quotedNumber))
+				source.Number = quotedNumber
+			}
+		}
+	}
+}
+
+// renderBody renders the HTML of a message body. The body of the parent
+// message is used to strip a quote of the parent message, and can be nil.
+func renderBody(messageBody parse.MessageBody, parentBody *parse.MessageBody, config OutputConfig) string {
+	tokens := messageBody.Tokens
+
+	if parentBody != nil {
+		tokens = body.StripParentQuote(tokens, parentBody.Tokens, config.ParentQuote)
+	}
+
+	return body.Render(body.CollapseQuotes(tokens, config.CollapseQuotes))
+}
@@ -20,24 +20,56 @@ const (
 
 type ParentArgs struct {
 	Index             int
-	PagePath          PagePath
+	Permalink         PagePath
 	User              string
 	Body              template.HTML
 	Timestamp         string
 	FormattedDatetime string
 }
 
-type MessageArgs struct {
+// MessageLinkArgs is a link from a message to a related message, like a reply
+// to it, which is on whichever thread page the related message ended up on.
+type MessageLinkArgs struct {
 	Index             int
+	Href              string
+	User              string
+	Timestamp         string
+	FormattedDatetime string
+}
+
+type MessageArgs struct {
+	// The position of the message in the thread, sorted by date.
+	Index int
+
+	// The number of the message used in its URLs. This is the same as `Index`
+	// unless messages have been added to the archive since the manifest was
+	// written, in which case messages keep the numbers they had before.
+	PermalinkNumber int
+
 	Number            string
 	TotalCount        string
+	Permalink         PagePath
+	PagePath          PagePath
+	TopicPermalink    PagePath
 	Timestamp         string
 	FormattedDatetime string
 	Parent            *ParentArgs
+	Replies           []MessageLinkArgs
+	PrevInTopic       *MessageLinkArgs
+	NextInTopic       *MessageLinkArgs
 	User              string
+	UserHref          PagePath
 	Flair             string
 	Title             string
 	Body              template.HTML
+	Text              string
+	Summary           string
+	SourceHref        string
+
+	messageID  parse.MessageID
+	date       time.Time
+	userSlug   string
+	pageNumber int
 }
 
 type PagePath string
@@ -59,7 +91,9 @@ type PaginationArgs struct {
 	Last             PagePath
 }
 
-type TemplateArgs struct {
+// SiteArgs are the template arguments which are the same for every page of
+// the site.
+type SiteArgs struct {
 	Title         string
 	Description   string
 	BaseUrl       string
@@ -67,8 +101,58 @@ type TemplateArgs struct {
 	Locale        string
 	IncludeSearch bool
 	Links         []ExternalLinkConfig
-	Messages      []MessageArgs
-	Pagination    PaginationArgs
+	Feeds         []FeedLinkArgs
+	ArchiveData   *ArchiveData
+
+	// The path of the page of a message, with a placeholder for its number.
+	MessagePermalinkPattern string
+}
+
+// PageMetaArgs are the template arguments for the metadata of a specific
+// page, like its canonical URL and its Open Graph tags.
+type PageMetaArgs struct {
+	Title       string
+	Description string
+	Canonical   PagePath
+	Type        string
+	Published   string
+	Next        *PagePath
+	Prev        *PagePath
+
+	// The feed of just this page, like the messages in a topic, as opposed to
+	// the feeds of the whole archive.
+	Feed *FeedLinkArgs
+}
+
+type TemplateArgs struct {
+	SiteArgs
+	Meta       PageMetaArgs
+	Messages   []MessageArgs
+	Pagination PaginationArgs
+}
+
+// MessageRef is a link to another message, with just enough information
+// about it to describe where the link goes.
+type MessageRef struct {
+	Index             int
+	Permalink         PagePath
+	User              string
+	Title             string
+	Timestamp         string
+	FormattedDatetime string
+	Summary           string
+}
+
+type MessagePageArgs struct {
+	SiteArgs
+	Meta    PageMetaArgs
+	Parent  *MessageArgs
+	Message MessageArgs
+	Replies []MessageArgs
+	Prev    *MessageRef
+	Next    *MessageRef
+
+	StructuredData *MessageData
 }
 
 func formatTimestamp(input time.Time) string {
@@ -84,29 +168,42 @@ func formatHumanReadableNumber(number int) string {
 	return localizedPrinter.Sprintf("%d", number)
 }
 
-func messageThreadToArgs(thread parse.MessageThread) []MessageArgs {
+func messageThreadToArgs(thread parse.MessageThread, config OutputConfig, manifest *Manifest) []MessageArgs {
 	argsList := make([]MessageArgs, len(thread))
 
 	messagesByDate, messageIndices := thread.SortedByDate()
+	numbers := messageNumbers(messagesByDate, manifest)
+
+	linkQuotedMessages(messagesByDate, numbers)
+
+	slugs := memberSlugs(messagesByDate)
+	linkAttributedMembers(messagesByDate, messageIndices, slugs)
 
 	for messageIndex, message := range messagesByDate {
+		summary := messageSearchText(message)
+
 		messageTitle := ""
 
 		if message.Title != nil {
 			messageTitle = *message.Title
 		}
 
-		var parentArgs *ParentArgs
+		var (
+			parentArgs *ParentArgs
+			parentBody *parse.MessageBody
+		)
 
 		if message.Parent != nil {
 			parentIndex, parentIndexExists := messageIndices[*message.Parent]
 			parent, parentExists := thread[*message.Parent]
 
 			if parentIndexExists && parentExists {
+				parentBody = &parent.Body
 				parentArgs = &ParentArgs{
 					Index:             parentIndex + 1,
+					Permalink:         messagePermalink(numbers[parent.ID]),
 					User:              parent.User,
-					Body:              template.HTML(strings.TrimSpace(body.IndentMultilineString(parent.Body.Html, messageParentBodyIndent))),
+					Body:              template.HTML(strings.TrimSpace(body.IndentMultilineString(renderBody(parent.Body, nil, config), messageParentBodyIndent))),
 					Timestamp:         formatTimestamp(parent.Date),
 					FormattedDatetime: formatDatetime(parent.Date),
 				}
@@ -115,21 +212,76 @@ func messageThreadToArgs(thread parse.MessageThread) []MessageArgs {
 
 		argsList[messageIndex] = MessageArgs{
 			Index:             messageIndex + 1,
+			PermalinkNumber:   numbers[message.ID],
 			Number:            formatHumanReadableNumber(messageIndex + 1),
 			TotalCount:        formatHumanReadableNumber(len(messagesByDate)),
+			Permalink:         messagePermalink(numbers[message.ID]),
 			Timestamp:         formatTimestamp(message.Date),
 			FormattedDatetime: formatDatetime(message.Date),
 			Parent:            parentArgs,
 			User:              message.User,
+			UserHref:          memberPagePath(slugs[message.User], firstPageNumber),
 			Flair:             message.Flair,
 			Title:             messageTitle,
-			Body:              template.HTML(strings.TrimSpace(body.IndentMultilineString(message.Body.Html, messageBodyIndent))),
+			Body:              template.HTML(strings.TrimSpace(body.IndentMultilineString(renderBody(message.Body, parentBody, config), messageBodyIndent))),
+			Text:              strings.TrimRight(message.Body.Text, "\n"),
+			Summary:           truncateMessageDescription(summary),
+			SourceHref:        sourceHref(message, config.Source),
+			messageID:         message.ID,
+			date:              message.Date,
+			userSlug:          slugs[message.User],
+		}
+	}
+
+	// The messages are sorted by date, so the replies to each message are too.
+	for _, message := range argsList {
+		if message.Parent == nil {
+			continue
 		}
+
+		parent := &argsList[message.Parent.Index-1]
+		parent.Replies = append(parent.Replies, messageLink(message))
 	}
 
 	return argsList
 }
 
+// messageLink returns a link to the given message. The link is set by
+// linkMessages once the messages have been paginated.
+func messageLink(message MessageArgs) MessageLinkArgs {
+	return MessageLinkArgs{
+		Index:             message.Index,
+		User:              message.User,
+		Timestamp:         message.Timestamp,
+		FormattedDatetime: message.FormattedDatetime,
+	}
+}
+
+// linkMessages sets the links between related messages, now that we know which
+// page each message is on.
+func linkMessages(messages []MessageArgs) {
+	setHref := func(link *MessageLinkArgs) {
+		target := messages[link.Index-1]
+		link.Href = fmt.Sprintf("%s#message-%d", target.PagePath, target.PermalinkNumber)
+	}
+
+	for messageIndex := range messages {
+		message := &messages[messageIndex]
+
+		for replyIndex := range message.Replies {
+			setHref(&message.Replies[replyIndex])
+		}
+
+		if message.PrevInTopic != nil {
+			setHref(message.PrevInTopic)
+		}
+
+		if message.NextInTopic != nil {
+			setHref(message.NextInTopic)
+		}
+	}
+}
+
 type ExternalLinkConfig struct {
 	IconName string
 	Label    string
@@ -145,6 +297,38 @@ type OutputConfig struct {
 	AddRepoLink       bool
 	Links             []ExternalLinkConfig
 	Locale            string
+	CollapseQuotes    body.CollapseConfig
+	ParentQuote       body.ParentQuoteMode
+	Source            SourceConfig
+
+	// How to split the messages into pages. This defaults to pages of
+	// `PageSize` messages.
+	Pagination PaginationStrategy
+
+	// The path of the manifest of which page each message is on, which is kept
+	// between builds so that the URLs of messages don't change. This is
+	// optional.
+	ManifestPath string
+
+	// Which Atom and RSS feeds to publish. Feeds are only published when
+	// `BaseUrl` is absolute, since the links in feeds need to be.
+	Feeds FeedConfig
+
+	// Whether to write a sitemap, which also requires `BaseUrl` to be absolute.
+	// This should be disabled when the site disallows crawling.
+	Sitemap bool
+
+	// The span of the messages in the archive, which is set by
+	// `withArchiveSpan` once the messages have been built.
+	archiveSpan archiveSpan
+}
+
+func (c OutputConfig) paginationStrategy() PaginationStrategy {
+	if c.Pagination == nil {
+		return FixedSizePagination{PageSize: c.PageSize}
+	}
+
+	return c.Pagination
 }
 
 func (c OutputConfig) Lang() string {
@@ -166,8 +350,8 @@ func pagePath(pageNumber int) PagePath {
 	}
 }
 
-func canonicalPagePath(baseUrl string, pageNumber int) PagePath {
-	return PagePath(strings.TrimSuffix(baseUrl, "/")) + pagePath(pageNumber)
+func canonicalUrl(baseUrl string, path PagePath) PagePath {
+	return PagePath(strings.TrimSuffix(baseUrl, "/")) + path
 }
 
 func navPagesRange(pageNumber, totalPages int) (first, last int) {
@@ -198,83 +382,134 @@ func calculateTotalPages(messages, pageSize int) int {
 	return messages / pageSize
 }
 
-func BuildArgs(thread parse.MessageThread, config OutputConfig) []TemplateArgs {
-	messages := messageThreadToArgs(thread)
+// buildPagination builds the navigation between numbered pages, given the
+// path of each page by its number.
+func buildPagination(pageNumber, totalPages int, pathOf func(pageNumber int) PagePath, baseUrl string) PaginationArgs {
+	firstPageInNav, lastPageInNav := navPagesRange(pageNumber, totalPages)
 
-	totalPages := calculateTotalPages(len(messages), config.PageSize)
+	var pageRefs []PageRef
 
-	var args []TemplateArgs
+	for pageInNavNumber := firstPageInNav; pageInNavNumber <= lastPageInNav; pageInNavNumber++ {
+		pageRefs = append(pageRefs, PageRef{
+			Path:      pathOf(pageInNavNumber),
+			Number:    pageInNavNumber,
+			IsCurrent: pageInNavNumber == pageNumber,
+		})
+	}
 
-	for pageNumber := firstPageNumber; pageNumber <= totalPages; pageNumber++ {
-		firstPageInNav, lastPageInNav := navPagesRange(pageNumber, totalPages)
+	paginationArgs := PaginationArgs{
+		Pages:            pageRefs,
+		PageNumber:       pageNumber,
+		Current:          pathOf(pageNumber),
+		CurrentCanonical: canonicalUrl(baseUrl, pathOf(pageNumber)),
+		First:            pathOf(firstPageNumber),
+		Last:             pathOf(totalPages),
+	}
 
-		var pageRefs []PageRef
+	if pageNumber > firstPageNumber {
+		prevPath := pathOf(pageNumber - 1)
+		paginationArgs.Prev = &prevPath
+	}
 
-		for pageInNavNumber := firstPageInNav; pageInNavNumber <= lastPageInNav; pageInNavNumber++ {
-			pageRefs = append(pageRefs, PageRef{
-				Path:      pagePath(pageInNavNumber),
-				Number:    pageInNavNumber,
-				IsCurrent: pageInNavNumber == pageNumber,
-			})
-		}
+	if pageNumber < totalPages {
+		nextPath := pathOf(pageNumber + 1)
+		paginationArgs.Next = &nextPath
+	}
 
-		paginationArgs := PaginationArgs{
-			Pages:            pageRefs,
-			PageNumber:       pageNumber,
-			Current:          pagePath(pageNumber),
-			CurrentCanonical: canonicalPagePath(config.BaseUrl, pageNumber),
-			First:            pagePath(firstPageNumber),
-			Last:             pagePath(totalPages),
-		}
+	return paginationArgs
+}
 
-		if pageNumber > firstPageNumber {
-			prevPath := pagePath(pageNumber - 1)
-			paginationArgs.Prev = &prevPath
-		}
+func (c OutputConfig) siteArgs() SiteArgs {
+	linkArgs := make([]ExternalLinkConfig, len(c.Links))
+	copy(linkArgs, c.Links)
 
-		if pageNumber < totalPages {
-			nextPath := pagePath(pageNumber + 1)
-			paginationArgs.Next = &nextPath
-		}
+	if c.AddRepoLink {
+		linkArgs = append(linkArgs, ExternalLinkConfig{
+			IconName: "github",
+			Label:    "GitHub",
+			Url:      repoUrl,
+		})
+	}
 
-		messageStartIndex := (pageNumber - 1) * config.PageSize
-		messageEndIndex := messageStartIndex + config.PageSize
-		if messageEndIndex > len(messages) {
-			messageEndIndex = len(messages)
-		}
+	return SiteArgs{
+		Title:         c.Title,
+		Description:   c.Description(),
+		BaseUrl:       c.BaseUrl,
+		Locale:        c.Locale,
+		Lang:          c.Lang(),
+		IncludeSearch: c.IncludeSearch,
+		Links:         linkArgs,
+		Feeds:         c.siteFeedLinks(),
+		ArchiveData:   c.archiveStructuredData(),
+
+		MessagePermalinkPattern: messagePermalinkPattern(),
+	}
+}
 
-		messagesInPage := messages[messageStartIndex:messageEndIndex]
+func buildThreadArgs(messages []MessageArgs, pageStarts []int, config OutputConfig) []TemplateArgs {
+	site := config.siteArgs()
 
-		for _, message := range messagesInPage {
-			if message.Parent != nil {
-				parentPageNumber := (message.Parent.Index / config.PageSize) + 1
-				message.Parent.PagePath = pagePath(parentPageNumber)
-			}
-		}
+	totalPages := len(pageStarts)
 
-		linkArgs := make([]ExternalLinkConfig, len(config.Links))
-		copy(linkArgs, config.Links)
+	var args []TemplateArgs
+
+	for pageNumber := firstPageNumber; pageNumber <= totalPages; pageNumber++ {
+		paginationArgs := buildPagination(pageNumber, totalPages, pagePath, config.BaseUrl)
 
-		if config.AddRepoLink {
-			linkArgs = append(linkArgs, ExternalLinkConfig{
-				IconName: "github",
-				Label:    "GitHub",
-				Url:      repoUrl,
-			})
+		messageStartIndex := pageStarts[pageNumber-firstPageNumber]
+		messageEndIndex := len(messages)
+		if pageNumber < totalPages {
+			messageEndIndex = pageStarts[pageNumber-firstPageNumber+1]
 		}
 
 		args = append(args, TemplateArgs{
-			Title:         config.Title,
-			Description:   config.Description(),
-			BaseUrl:       config.BaseUrl,
-			Locale:        config.Locale,
-			Lang:          config.Lang(),
-			IncludeSearch: config.IncludeSearch,
-			Links:         linkArgs,
-			Messages:      messagesInPage,
-			Pagination:    paginationArgs,
+			SiteArgs: site,
+			Meta: PageMetaArgs{
+				Title:       site.Title,
+				Description: site.Description,
+				Canonical:   paginationArgs.CurrentCanonical,
+				Type:        "website",
+				Next:        paginationArgs.Next,
+				Prev:        paginationArgs.Prev,
+			},
+			Messages:   messages[messageStartIndex:messageEndIndex],
+			Pagination: paginationArgs,
 		})
 	}
 
 	return args
 }
+
+// builtThread is the messages in the thread once they've been rendered, grouped
+// into topics, and split into pages, which every kind of page is built from.
+type builtThread struct {
+	messages   []MessageArgs
+	topics     []topic
+	pageStarts []int
+
+	// The manifest from the previous build, if there is one.
+	manifest *Manifest
+}
+
+func buildThread(thread parse.MessageThread, config OutputConfig, manifest *Manifest) builtThread {
+	messages := messageThreadToArgs(thread, config, manifest)
+
+	// Topics need to be grouped first, since messages can be paginated by topic.
+	topics := groupTopics(messages)
+	pageStarts := paginate(messages, config.paginationStrategy())
+	linkMessages(messages)
+	refreshTopicMessages(topics, messages)
+
+	return builtThread{messages: messages, topics: topics, pageStarts: pageStarts, manifest: manifest}
+}
+
+func BuildArgs(thread parse.MessageThread, config OutputConfig) ([]TemplateArgs, error) {
+	manifest, err := ReadManifest(config.ManifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	built := buildThread(thread, config, manifest)
+
+	return buildThreadArgs(built.messages, built.pageStarts, config.withArchiveSpan(built.messages)), nil
+}
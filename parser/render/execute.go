@@ -4,7 +4,6 @@ import (
 	"github.com/acearchive/yahoo-groups-reader/parse"
 	"os"
 	"path/filepath"
-	"strconv"
 )
 
 const (
@@ -17,26 +16,31 @@ func Execute(path string, config OutputConfig, thread parse.MessageThread) error
 		return err
 	}
 
-	pages := BuildArgs(thread, config)
+	manifest, err := ReadManifest(config.ManifestPath)
+	if err != nil {
+		return err
+	}
 
-	for pageIndex, args := range pages {
-		var outputFilePath string
+	built := buildThread(thread, config, manifest)
 
-		if pageIndex == 0 {
-			outputFilePath = filepath.Join(path, "index.html")
-		} else {
-			if err := os.Mkdir(filepath.Join(path, strconv.Itoa(pageIndex+1)), outputDirMode); err != nil {
-				return err
-			}
-			outputFilePath = filepath.Join(path, strconv.Itoa(pageIndex+1), "index.html")
+	pages, redirects, err := buildPages(built, config)
+	if err != nil {
+		return err
+	}
+
+	for _, page := range pages {
+		pageDirPath := filepath.Join(path, filepath.FromSlash(string(page.Path)))
+
+		if err := os.MkdirAll(pageDirPath, outputDirMode); err != nil {
+			return err
 		}
 
-		file, err := os.OpenFile(outputFilePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, outputFileMode)
+		file, err := os.OpenFile(filepath.Join(pageDirPath, "index.html"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, outputFileMode)
 		if err != nil {
 			return err
 		}
 
-		if err := Template.Execute(file, args); err != nil {
+		if err := Templates.ExecuteTemplate(file, page.Template, page.Args); err != nil {
 			return err
 		}
 
@@ -45,8 +49,38 @@ func Execute(path string, config OutputConfig, thread parse.MessageThread) error
 		}
 	}
 
+	if config.Source.Publish {
+		if err := writeSources(thread, config.Source, path); err != nil {
+			return err
+		}
+	}
+
 	if config.IncludeSearch {
-		if err := writeSearchData(thread, config, path); err != nil {
+		if err := writeSearchData(thread, built.messages, path); err != nil {
+			return err
+		}
+	}
+
+	if config.Feeds.Publish {
+		if err := writeFeeds(thread, built, config, path); err != nil {
+			return err
+		}
+	}
+
+	if config.Sitemap {
+		if err := writeSitemap(pages, config, path); err != nil {
+			return err
+		}
+	}
+
+	if config.ManifestPath != "" {
+		if err := writeRedirects(redirects, path); err != nil {
+			return err
+		}
+
+		// The manifest is written last so that it's only updated once the site
+		// has been built.
+		if err := writeManifest(buildManifest(built, redirects), config.ManifestPath); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,448 @@
+package render
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/acearchive/yahoo-groups-reader/body"
+	"github.com/acearchive/yahoo-groups-reader/logger"
+	"github.com/acearchive/yahoo-groups-reader/parse"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	atomFeedFileName = "feed.xml"
+	rssFeedFileName  = "rss.xml"
+)
+
+// The number of the most recent messages to include in each feed. Feed readers
+// only need to see what's new, and an archive can have many thousands of
+// messages.
+const feedEntryLimit = 50
+
+const (
+	atomNamespace        = "http://www.w3.org/2005/Atom"
+	dublinCoreNamespace  = "http://purl.org/dc/elements/1.1/"
+	feedGeneratorName    = "yahoo-groups-reader"
+	atomFeedContentType  = "application/atom+xml"
+	atomEntryContentType = "html"
+)
+
+var ErrRelativeBaseUrl = errors.New("the base URL is not absolute")
+
+var (
+	feedSvgRegex          = regexp.MustCompile(`(?s)<svg\b.*?</svg>`)
+	feedRelativeHrefRegex = regexp.MustCompile(`href="/([^/])`)
+)
+
+// FeedConfig determines which Atom and RSS feeds are published.
+type FeedConfig struct {
+	Publish bool
+	Rss     bool
+
+	// Publish a feed for each topic and each member, as well as the feed of the
+	// whole archive.
+	PerTopic  bool
+	PerMember bool
+}
+
+// FeedLinkArgs is a feed which is linked to from the `<head>` of a page so
+// that feed readers can find it.
+type FeedLinkArgs struct {
+	Title string
+	Type  string
+	Href  string
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	Uri  string `xml:"uri,omitempty"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomGenerator struct {
+	Uri  string `xml:"uri,attr"`
+	Name string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	Id        string      `xml:"id"`
+	Link      atomLink    `xml:"link"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Author    atomAuthor  `xml:"author"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomFeed struct {
+	XMLName   xml.Name      `xml:"feed"`
+	Namespace string        `xml:"xmlns,attr"`
+	Lang      string        `xml:"xml:lang,attr"`
+	Title     string        `xml:"title"`
+	Subtitle  string        `xml:"subtitle"`
+	Id        string        `xml:"id"`
+	Links     []atomLink    `xml:"link"`
+	Updated   string        `xml:"updated"`
+	Generator atomGenerator `xml:"generator"`
+	Entries   []atomEntry   `xml:"entry"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Guid        rssGuid `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Creator     string  `xml:"dc:creator"`
+	Description string  `xml:"description"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Generator     string    `xml:"generator"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName             xml.Name   `xml:"rss"`
+	Version             string     `xml:"version,attr"`
+	AtomNamespace       string     `xml:"xmlns:atom,attr"`
+	DublinCoreNamespace string     `xml:"xmlns:dc,attr"`
+	Channel             rssChannel `xml:"channel"`
+}
+
+// feed is the messages in one of the feeds, which is written as Atom and
+// optionally as RSS.
+type feed struct {
+	title       string
+	description string
+
+	// The path of the page the feed is for and the directory the feed is
+	// written to.
+	path     PagePath
+	messages []MessageArgs
+}
+
+// isAbsoluteUrl returns whether the URL includes the scheme and host, which is
+// required for the links in feeds.
+func isAbsoluteUrl(rawUrl string) bool {
+	parsed, err := url.Parse(rawUrl)
+	return err == nil && parsed.Scheme != "" && parsed.Host != ""
+}
+
+// publishesFeeds returns whether feeds are published, which they can't be
+// without an absolute base URL.
+func (c OutputConfig) publishesFeeds() bool {
+	return c.Feeds.Publish && isAbsoluteUrl(c.BaseUrl)
+}
+
+func feedPath(pagePath PagePath, fileName string) PagePath {
+	return PagePath(fmt.Sprintf("%s%s", pagePath, fileName))
+}
+
+// siteFeedLinks returns the feeds of the whole archive to link to from every
+// page.
+func (c OutputConfig) siteFeedLinks() []FeedLinkArgs {
+	if !c.publishesFeeds() {
+		return nil
+	}
+
+	links := []FeedLinkArgs{{
+		Title: c.Title,
+		Type:  atomFeedContentType,
+		Href:  string(canonicalUrl(c.BaseUrl, feedPath("/", atomFeedFileName))),
+	}}
+
+	if c.Feeds.Rss {
+		links = append(links, FeedLinkArgs{
+			Title: c.Title,
+			Type:  "application/rss+xml",
+			Href:  string(canonicalUrl(c.BaseUrl, feedPath("/", rssFeedFileName))),
+		})
+	}
+
+	return links
+}
+
+// pageFeedLink returns the feed of a topic or member page, if there is one.
+func pageFeedLink(isPublished bool, title string, pagePath PagePath, config OutputConfig) *FeedLinkArgs {
+	if !isPublished || !config.publishesFeeds() {
+		return nil
+	}
+
+	return &FeedLinkArgs{
+		Title: title,
+		Type:  atomFeedContentType,
+		Href:  string(canonicalUrl(config.BaseUrl, feedPath(pagePath, atomFeedFileName))),
+	}
+}
+
+// feedContent renders the body of a message for a feed. Feed readers show the
+// message on its own, so the quote of the parent message isn't stripped, and
+// the links need to be absolute. The icons are removed since feed readers
+// don't have our styles to size them.
+func feedContent(message parse.Message, baseUrl string) string {
+	content := body.Render(message.Body.Tokens)
+	content = feedSvgRegex.ReplaceAllString(content, "")
+
+	return feedRelativeHrefRegex.ReplaceAllStringFunc(content, func(match string) string {
+		return fmt.Sprintf(`href="%s/%s`, strings.TrimSuffix(baseUrl, "/"), strings.TrimPrefix(match, `href="/`))
+	})
+}
+
+// recentMessages returns the most recent messages for a feed, newest first.
+func recentMessages(messages []MessageArgs) []MessageArgs {
+	start := len(messages) - feedEntryLimit
+	if start < 0 {
+		start = 0
+	}
+
+	recent := make([]MessageArgs, 0, len(messages)-start)
+
+	for i := len(messages) - 1; i >= start; i-- {
+		recent = append(recent, messages[i])
+	}
+
+	return recent
+}
+
+func (f feed) atom(contents []string, config OutputConfig) atomFeed {
+	pageUrl := string(canonicalUrl(config.BaseUrl, f.path))
+
+	output := atomFeed{
+		Namespace: atomNamespace,
+		Lang:      config.Lang(),
+		Title:     f.title,
+		Subtitle:  f.description,
+		Id:        pageUrl,
+		Links: []atomLink{
+			{Rel: "self", Type: atomFeedContentType, Href: string(canonicalUrl(config.BaseUrl, feedPath(f.path, atomFeedFileName)))},
+			{Rel: "alternate", Type: "text/html", Href: pageUrl},
+		},
+		Generator: atomGenerator{Uri: repoUrl, Name: feedGeneratorName},
+		Entries:   make([]atomEntry, len(f.messages)),
+	}
+
+	for i, message := range f.messages {
+		permalink := string(canonicalUrl(config.BaseUrl, message.Permalink))
+		timestamp := message.date.Format(time.RFC3339)
+
+		output.Entries[i] = atomEntry{
+			Title:     messageHeadline(message),
+			Id:        permalink,
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: permalink},
+			Published: timestamp,
+			Updated:   timestamp,
+			Author: atomAuthor{
+				Name: message.User,
+				Uri:  string(canonicalUrl(config.BaseUrl, message.UserHref)),
+			},
+			Content: atomContent{Type: atomEntryContentType, Body: contents[message.Index-1]},
+		}
+	}
+
+	// Atom requires the feed to have an update time, and the most recent message
+	// is the closest thing we have to one.
+	if len(f.messages) > 0 {
+		output.Updated = output.Entries[0].Updated
+	}
+
+	return output
+}
+
+func (f feed) rss(contents []string, config OutputConfig) rssFeed {
+	channel := rssChannel{
+		Title:       f.title,
+		Link:        string(canonicalUrl(config.BaseUrl, f.path)),
+		Description: f.description,
+		Language:    config.Lang(),
+		Generator:   feedGeneratorName,
+		AtomLink: atomLink{
+			Rel:  "self",
+			Type: "application/rss+xml",
+			Href: string(canonicalUrl(config.BaseUrl, feedPath(f.path, rssFeedFileName))),
+		},
+		Items: make([]rssItem, len(f.messages)),
+	}
+
+	for i, message := range f.messages {
+		permalink := string(canonicalUrl(config.BaseUrl, message.Permalink))
+
+		channel.Items[i] = rssItem{
+			Title:       messageHeadline(message),
+			Link:        permalink,
+			Guid:        rssGuid{IsPermaLink: true, Value: permalink},
+			PubDate:     message.date.Format(time.RFC1123Z),
+			Creator:     message.User,
+			Description: contents[message.Index-1],
+		}
+	}
+
+	if len(f.messages) > 0 {
+		channel.LastBuildDate = channel.Items[0].PubDate
+	}
+
+	return rssFeed{
+		Version:             "2.0",
+		AtomNamespace:       atomNamespace,
+		DublinCoreNamespace: dublinCoreNamespace,
+		Channel:             channel,
+	}
+}
+
+func buildFeeds(built builtThread, config OutputConfig) []feed {
+	site := config.siteArgs()
+
+	feeds := []feed{{
+		title:       site.Title,
+		description: site.Description,
+		path:        "/",
+		messages:    recentMessages(built.messages),
+	}}
+
+	if config.Feeds.PerTopic {
+		for topicIndex, topic := range built.topics {
+			summary := topic.summary(topicIndex + 1)
+
+			messages := make([]MessageArgs, len(topic.messages))
+
+			for i, message := range topic.messages {
+				messages[i] = built.messages[message.Index-1]
+			}
+
+			// The messages in a topic are in reply order, but feeds are in the
+			// order the messages were sent.
+			sort.Slice(messages, func(i, j int) bool {
+				return messages[i].Index < messages[j].Index
+			})
+
+			feeds = append(feeds, feed{
+				title:       fmt.Sprintf("%s - %s", summary.Title, site.Title),
+				description: fmt.Sprintf("Messages in the topic \"%s\" in %s.", summary.Title, site.Title),
+				path:        summary.Permalink,
+				messages:    recentMessages(messages),
+			})
+		}
+	}
+
+	if config.Feeds.PerMember {
+		var (
+			slugs             []string
+			messagesBySlug    = make(map[string][]MessageArgs)
+			memberPermalinks  = make(map[string]PagePath)
+			memberNamesBySlug = make(map[string]string)
+		)
+
+		for _, message := range built.messages {
+			if _, exists := messagesBySlug[message.userSlug]; !exists {
+				slugs = append(slugs, message.userSlug)
+				memberPermalinks[message.userSlug] = message.UserHref
+				memberNamesBySlug[message.userSlug] = message.User
+			}
+
+			messagesBySlug[message.userSlug] = append(messagesBySlug[message.userSlug], message)
+		}
+
+		for _, slug := range slugs {
+			feeds = append(feeds, feed{
+				title:       fmt.Sprintf("%s - %s", memberNamesBySlug[slug], site.Title),
+				description: fmt.Sprintf("Messages posted by %s in %s.", memberNamesBySlug[slug], site.Title),
+				path:        memberPermalinks[slug],
+				messages:    recentMessages(messagesBySlug[slug]),
+			})
+		}
+	}
+
+	return feeds
+}
+
+func writeXmlFile(path string, value interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), outputDirMode); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, outputFileMode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.WriteString(xml.Header); err != nil {
+		file.Close()
+		return err
+	}
+
+	encoder := xml.NewEncoder(file)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(value); err != nil {
+		file.Close()
+		return err
+	}
+
+	if _, err := file.WriteString("\n"); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// writeFeeds writes the Atom feeds, and the RSS feed of the whole archive if
+// it's enabled.
+func writeFeeds(thread parse.MessageThread, built builtThread, config OutputConfig, path string) error {
+	if !isAbsoluteUrl(config.BaseUrl) {
+		logger.Verbose.Printf("%v, so no feeds will be published: '%s'", ErrRelativeBaseUrl, config.BaseUrl)
+		return nil
+	}
+
+	sortedMessages, _ := thread.SortedByDate()
+	contents := make([]string, len(sortedMessages))
+
+	for i, message := range sortedMessages {
+		contents[i] = feedContent(message, config.BaseUrl)
+	}
+
+	for feedIndex, feed := range buildFeeds(built, config) {
+		feedDir := filepath.Join(path, filepath.FromSlash(string(feed.path)))
+
+		if err := writeXmlFile(filepath.Join(feedDir, atomFeedFileName), feed.atom(contents, config)); err != nil {
+			return err
+		}
+
+		// Only the feed of the whole archive is published as RSS, since it's
+		// just for feed readers which don't support Atom.
+		if feedIndex == 0 && config.Feeds.Rss {
+			if err := writeXmlFile(filepath.Join(feedDir, rssFeedFileName), feed.rss(contents, config)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
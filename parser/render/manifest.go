@@ -0,0 +1,311 @@
+package render
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/acearchive/yahoo-groups-reader/parse"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const manifestVersion = 1
+
+const (
+	redirectsFileName      = "_redirects"
+	nginxRedirectsFileName = "redirects.nginx.conf"
+)
+
+// The name of the variable the nginx map of redirects sets.
+const nginxRedirectVariable = "$yahoo_groups_reader_redirect"
+
+var ErrUnsupportedManifest = errors.New("unsupported manifest version")
+
+// ManifestEntry is the number of a message and the thread page it was on when
+// the manifest was written.
+type ManifestEntry struct {
+	ID     parse.MessageID `json:"id"`
+	Number int             `json:"number"`
+	Page   PagePath        `json:"page"`
+}
+
+// Manifest records which URLs each message was published at, so that the next
+// build can keep the same message numbers and redirect thread pages which no
+// longer exist.
+type Manifest struct {
+	Version int `json:"version"`
+
+	// The highest message number which has been used, so that the numbers of
+	// messages which have been removed from the archive aren't reused.
+	LastNumber int             `json:"lastNumber"`
+	Messages   []ManifestEntry `json:"messages"`
+
+	// Thread pages which no longer exist, by the message which was first on
+	// each page.
+	RetiredPages map[PagePath]parse.MessageID `json:"retiredPages,omitempty"`
+}
+
+// redirect is a path which used to be a thread page and the URL of the
+// message which was on it.
+type redirect struct {
+	From      PagePath
+	To        string
+	permalink PagePath
+	messageID parse.MessageID
+}
+
+type RedirectPageArgs struct {
+	SiteArgs
+	Meta   PageMetaArgs
+	From   PagePath
+	Target string
+}
+
+// ReadManifest reads the manifest at the given path. It returns nil if the path
+// is empty or the manifest doesn't exist yet, which is the case the first time
+// the site is built.
+func ReadManifest(path string) (*Manifest, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var manifest Manifest
+
+	if err := json.NewDecoder(file).Decode(&manifest); err != nil {
+		return nil, err
+	}
+
+	if manifest.Version != manifestVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedManifest, manifest.Version)
+	}
+
+	return &manifest, nil
+}
+
+// messageNumbers returns the number of each message used in its URLs. Messages
+// in the manifest keep the number they had before, and new messages are
+// numbered after them in the order they were sent. Without a manifest, this is
+// just the position of each message.
+func messageNumbers(messagesByDate []parse.Message, manifest *Manifest) map[parse.MessageID]int {
+	numbers := make(map[parse.MessageID]int, len(messagesByDate))
+
+	if manifest == nil {
+		for i, message := range messagesByDate {
+			numbers[message.ID] = i + 1
+		}
+
+		return numbers
+	}
+
+	previousNumbers := make(map[parse.MessageID]int, len(manifest.Messages))
+
+	for _, entry := range manifest.Messages {
+		previousNumbers[entry.ID] = entry.Number
+	}
+
+	nextNumber := manifest.LastNumber + 1
+
+	for _, message := range messagesByDate {
+		if number, exists := previousNumbers[message.ID]; exists {
+			numbers[message.ID] = number
+		} else {
+			numbers[message.ID] = nextNumber
+			nextNumber++
+		}
+	}
+
+	return numbers
+}
+
+// buildRedirects returns a redirect for each thread page in the manifest which
+// is no longer a page of the site, to the first message on it which is still in
+// the archive.
+func buildRedirects(built builtThread, pagePaths map[PagePath]struct{}) []redirect {
+	if built.manifest == nil {
+		return nil
+	}
+
+	messagesByID := make(map[parse.MessageID]MessageArgs, len(built.messages))
+
+	for _, message := range built.messages {
+		messagesByID[message.messageID] = message
+	}
+
+	targets := make(map[PagePath]parse.MessageID)
+
+	for path, id := range built.manifest.RetiredPages {
+		targets[path] = id
+	}
+
+	// The manifest is in the order the messages were sent, so this finds the
+	// first message on each page.
+	for _, entry := range built.manifest.Messages {
+		if _, exists := targets[entry.Page]; exists {
+			continue
+		}
+
+		if _, exists := messagesByID[entry.ID]; exists {
+			targets[entry.Page] = entry.ID
+		}
+	}
+
+	var redirects []redirect
+
+	for path, id := range targets {
+		if _, isPage := pagePaths[path]; isPage {
+			continue
+		}
+
+		message, exists := messagesByID[id]
+		if !exists {
+			continue
+		}
+
+		redirects = append(redirects, redirect{
+			From:      path,
+			To:        fmt.Sprintf("%s#message-%d", message.PagePath, message.PermalinkNumber),
+			permalink: message.Permalink,
+			messageID: id,
+		})
+	}
+
+	sort.Slice(redirects, func(i, j int) bool {
+		return redirects[i].From < redirects[j].From
+	})
+
+	return redirects
+}
+
+func buildRedirectPageArgs(redirects []redirect, config OutputConfig) []RedirectPageArgs {
+	site := config.siteArgs()
+
+	args := make([]RedirectPageArgs, len(redirects))
+
+	for i, redirect := range redirects {
+		args[i] = RedirectPageArgs{
+			SiteArgs: site,
+			Meta: PageMetaArgs{
+				Title:       site.Title,
+				Description: site.Description,
+				Canonical:   canonicalUrl(config.BaseUrl, redirect.permalink),
+				Type:        "website",
+			},
+			From:   redirect.From,
+			Target: redirect.To,
+		}
+	}
+
+	return args
+}
+
+func buildManifest(built builtThread, redirects []redirect) Manifest {
+	manifest := Manifest{
+		Version:  manifestVersion,
+		Messages: make([]ManifestEntry, len(built.messages)),
+	}
+
+	if built.manifest != nil {
+		manifest.LastNumber = built.manifest.LastNumber
+	}
+
+	for i, message := range built.messages {
+		manifest.Messages[i] = ManifestEntry{ID: message.messageID, Number: message.PermalinkNumber, Page: message.PagePath}
+
+		if message.PermalinkNumber > manifest.LastNumber {
+			manifest.LastNumber = message.PermalinkNumber
+		}
+	}
+
+	if len(redirects) > 0 {
+		manifest.RetiredPages = make(map[PagePath]parse.MessageID, len(redirects))
+
+		for _, redirect := range redirects {
+			manifest.RetiredPages[redirect.From] = redirect.messageID
+		}
+	}
+
+	return manifest
+}
+
+// writeManifest replaces the manifest at the given path. The new manifest is
+// written to a temporary file first so that a failed build doesn't leave a
+// truncated manifest behind.
+func writeManifest(manifest Manifest, path string) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(manifest); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// writeRedirects writes the redirects as a `_redirects` file for Netlify and
+// Cloudflare Pages and as an nginx map.
+func writeRedirects(redirects []redirect, path string) error {
+	var (
+		netlify strings.Builder
+		nginx   strings.Builder
+	)
+
+	nginx.WriteString("# Include this file in the `http` block and add this to the `server` block:\n")
+	nginx.WriteString("#\n")
+	fmt.Fprintf(&nginx, "#     if (%s) {\n", nginxRedirectVariable)
+	fmt.Fprintf(&nginx, "#         return 301 %s;\n", nginxRedirectVariable)
+	nginx.WriteString("#     }\n")
+	fmt.Fprintf(&nginx, "map $uri %s {\n", nginxRedirectVariable)
+	nginx.WriteString("    default \"\";\n")
+
+	for _, redirect := range redirects {
+		fmt.Fprintf(&netlify, "%s %s 301\n", redirect.From, redirect.To)
+		fmt.Fprintf(&nginx, "    \"%s\" \"%s\";\n", redirect.From, redirect.To)
+	}
+
+	nginx.WriteString("}\n")
+
+	if err := writeOutputFile(filepath.Join(path, redirectsFileName), netlify.String()); err != nil {
+		return err
+	}
+
+	return writeOutputFile(filepath.Join(path, nginxRedirectsFileName), nginx.String())
+}
+
+func writeOutputFile(path, contents string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, outputFileMode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.WriteString(contents); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,377 @@
+package render
+
+import (
+	"fmt"
+	"github.com/acearchive/yahoo-groups-reader/block"
+	"github.com/acearchive/yahoo-groups-reader/body"
+	"github.com/acearchive/yahoo-groups-reader/parse"
+	"golang.org/x/text/unicode/norm"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const memberDirName = "members"
+
+// The number of people to show on a profile page as the ones someone most
+// often replied to.
+const maxRepliedToMembers = 10
+
+// fallbackMemberSlug is used for names which don't contain any characters we
+// can use in a URL, like names written entirely in a non-Latin script.
+const fallbackMemberSlug = "member"
+
+type MemberSummaryArgs struct {
+	Name               string
+	Permalink          PagePath
+	Flair              string
+	MessageCount       int
+	FirstPostTimestamp string
+	FirstPostDatetime  string
+	LastPostTimestamp  string
+	LastPostDatetime   string
+}
+
+// YearCountArgs is the number of messages someone posted in a year, for the
+// histogram on their profile.
+type YearCountArgs struct {
+	Year  int
+	Count int
+}
+
+// RepliedToArgs is someone that a member replied to, and how many times.
+type RepliedToArgs struct {
+	Name      string
+	Permalink PagePath
+	Count     int
+}
+
+type MemberIndexArgs struct {
+	SiteArgs
+	Meta    PageMetaArgs
+	Members []MemberSummaryArgs
+}
+
+type MemberPageArgs struct {
+	SiteArgs
+	Meta          PageMetaArgs
+	Member        MemberSummaryArgs
+	PostsPerYear  []YearCountArgs
+	MaxYearCount  int
+	TopicsStarted []TopicSummaryArgs
+	RepliedTo     []RepliedToArgs
+	Messages      []MessageRef
+	Pagination    PaginationArgs
+}
+
+func memberIndexPath() PagePath {
+	return PagePath(fmt.Sprintf("/%s/", memberDirName))
+}
+
+func memberPagePath(slug string, pageNumber int) PagePath {
+	if pageNumber == firstPageNumber {
+		return PagePath(fmt.Sprintf("/%s/%s/", memberDirName, slug))
+	}
+
+	return PagePath(fmt.Sprintf("/%s/%s/%d/", memberDirName, slug, pageNumber))
+}
+
+// memberSlug returns the part of the URL of a profile page which identifies
+// the person. Accents are stripped so that names in Latin scripts still read
+// naturally in the URL.
+func memberSlug(name string) string {
+	var builder strings.Builder
+
+	isSeparatorPending := false
+
+	for _, char := range norm.NFKD.String(strings.ToLower(name)) {
+		switch {
+		case unicode.Is(unicode.Mn, char):
+			continue
+		case char <= unicode.MaxASCII && (unicode.IsLetter(char) || unicode.IsDigit(char)):
+			if isSeparatorPending && builder.Len() > 0 {
+				builder.WriteRune('-')
+			}
+
+			isSeparatorPending = false
+			builder.WriteRune(char)
+		default:
+			isSeparatorPending = true
+		}
+	}
+
+	if builder.Len() == 0 {
+		return fallbackMemberSlug
+	}
+
+	return builder.String()
+}
+
+// memberSlugs returns the slug of each person who posted in the archive, by
+// their name. When two names have the same slug, the person who posted later
+// gets a number added to theirs.
+func memberSlugs(messagesByDate []parse.Message) map[string]string {
+	slugs := make(map[string]string)
+	usedSlugs := make(map[string]struct{})
+
+	for _, message := range messagesByDate {
+		if _, exists := slugs[message.User]; exists {
+			continue
+		}
+
+		baseSlug := memberSlug(message.User)
+		slug := baseSlug
+
+		for suffix := 2; ; suffix++ {
+			if _, isUsed := usedSlugs[slug]; !isUsed {
+				break
+			}
+
+			slug = fmt.Sprintf("%s-%d", baseSlug, suffix)
+		}
+
+		usedSlugs[slug] = struct{}{}
+		slugs[message.User] = slug
+	}
+
+	return slugs
+}
+
+// linkAttributedMembers links the names in attribution lines to the profile
+// pages of the people they name. If the attribution is linked to the message
+// it quotes, we link to the person who sent that message. Otherwise, we look
+// for someone in the archive with the same name.
+func linkAttributedMembers(messagesByDate []parse.Message, messageIndices map[parse.MessageID]int, slugs map[string]string) {
+	slugsByNormalizedName := make(map[string]string)
+
+	// When several people have the same name once it's normalized, the first
+	// one to post gets the links.
+	for _, message := range messagesByDate {
+		normalizedName := normalizeMemberName(message.User)
+		if _, exists := slugsByNormalizedName[normalizedName]; !exists {
+			slugsByNormalizedName[normalizedName] = slugs[message.User]
+		}
+	}
+
+	for _, message := range messagesByDate {
+		for _, linkable := range body.LinkableBlocks(message.Body.Tokens) {
+			attribution, isAttribution := linkable.(*block.AttributionBlock)
+			if !isAttribution {
+				continue
+			}
+
+			if quotedIndex, isArchived := messageIndices[parse.MessageID(attribution.MessageID)]; isArchived {
+				attribution.NameHref = string(memberPagePath(slugs[messagesByDate[quotedIndex].User], firstPageNumber))
+			} else if slug, exists := slugsByNormalizedName[normalizeMemberName(attribution.Name)]; exists {
+				attribution.NameHref = string(memberPagePath(slug, firstPageNumber))
+			}
+		}
+	}
+}
+
+func normalizeMemberName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+type member struct {
+	messages []MessageArgs
+}
+
+func (m member) summary() MemberSummaryArgs {
+	first, last := m.messages[0], m.messages[len(m.messages)-1]
+
+	flair := ""
+
+	// Use the most recent flair, since it's the closest to how they described
+	// themselves by the end.
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Flair != "" {
+			flair = m.messages[i].Flair
+			break
+		}
+	}
+
+	return MemberSummaryArgs{
+		Name:               first.User,
+		Permalink:          first.UserHref,
+		Flair:              flair,
+		MessageCount:       len(m.messages),
+		FirstPostTimestamp: first.Timestamp,
+		FirstPostDatetime:  first.FormattedDatetime,
+		LastPostTimestamp:  last.Timestamp,
+		LastPostDatetime:   last.FormattedDatetime,
+	}
+}
+
+func (m member) postsPerYear() (counts []YearCountArgs, maxCount int) {
+	firstYear, lastYear := m.messages[0].date.Year(), m.messages[len(m.messages)-1].date.Year()
+
+	// Messages are sorted by date, but they're not all in the same time zone, so
+	// the years might not be in order at the boundaries.
+	for _, message := range m.messages {
+		if year := message.date.Year(); year < firstYear {
+			firstYear = year
+		} else if year > lastYear {
+			lastYear = year
+		}
+	}
+
+	counts = make([]YearCountArgs, lastYear-firstYear+1)
+
+	for i := range counts {
+		counts[i].Year = firstYear + i
+	}
+
+	for _, message := range m.messages {
+		yearCount := &counts[message.date.Year()-firstYear]
+		yearCount.Count++
+
+		if yearCount.Count > maxCount {
+			maxCount = yearCount.Count
+		}
+	}
+
+	return counts, maxCount
+}
+
+func (m member) repliedTo(messages []MessageArgs) []RepliedToArgs {
+	countsByName := make(map[string]*RepliedToArgs)
+
+	for _, message := range m.messages {
+		if message.Parent == nil {
+			continue
+		}
+
+		parent := messages[message.Parent.Index-1]
+		if parent.User == message.User {
+			continue
+		}
+
+		if _, exists := countsByName[parent.User]; !exists {
+			countsByName[parent.User] = &RepliedToArgs{Name: parent.User, Permalink: parent.UserHref}
+		}
+
+		countsByName[parent.User].Count++
+	}
+
+	repliedTo := make([]RepliedToArgs, 0, len(countsByName))
+
+	for _, count := range countsByName {
+		repliedTo = append(repliedTo, *count)
+	}
+
+	sort.Slice(repliedTo, func(i, j int) bool {
+		if repliedTo[i].Count != repliedTo[j].Count {
+			return repliedTo[i].Count > repliedTo[j].Count
+		}
+
+		return repliedTo[i].Name < repliedTo[j].Name
+	})
+
+	if len(repliedTo) > maxRepliedToMembers {
+		repliedTo = repliedTo[:maxRepliedToMembers]
+	}
+
+	return repliedTo
+}
+
+func buildMemberArgs(messages []MessageArgs, topics []TopicPageArgs, config OutputConfig) (MemberIndexArgs, []MemberPageArgs) {
+	site := config.siteArgs()
+
+	var (
+		members       []*member
+		membersByName = make(map[string]*member)
+	)
+
+	for _, message := range messages {
+		if _, exists := membersByName[message.User]; !exists {
+			membersByName[message.User] = &member{}
+			members = append(members, membersByName[message.User])
+		}
+
+		membersByName[message.User].messages = append(membersByName[message.User].messages, message)
+	}
+
+	topicsByStarter := make(map[string][]TopicSummaryArgs)
+
+	for _, topic := range topics {
+		topicsByStarter[topic.Topic.Starter] = append(topicsByStarter[topic.Topic.Starter], topic.Topic)
+	}
+
+	summaries := make([]MemberSummaryArgs, len(members))
+
+	var pages []MemberPageArgs
+
+	for memberIndex, member := range members {
+		summary := member.summary()
+		summaries[memberIndex] = summary
+
+		postsPerYear, maxYearCount := member.postsPerYear()
+		repliedTo := member.repliedTo(messages)
+		slug := member.messages[0].userSlug
+
+		pathOf := func(pageNumber int) PagePath {
+			return memberPagePath(slug, pageNumber)
+		}
+
+		description := fmt.Sprintf("%s has posted %d messages in %s.", summary.Name, summary.MessageCount, site.Title)
+		if summary.MessageCount == 1 {
+			description = fmt.Sprintf("%s has posted 1 message in %s.", summary.Name, site.Title)
+		}
+
+		totalPages := calculateTotalPages(len(member.messages), config.PageSize)
+
+		for pageNumber := firstPageNumber; pageNumber <= totalPages; pageNumber++ {
+			paginationArgs := buildPagination(pageNumber, totalPages, pathOf, config.BaseUrl)
+
+			messageStartIndex := (pageNumber - 1) * config.PageSize
+			messageEndIndex := messageStartIndex + config.PageSize
+			if messageEndIndex > len(member.messages) {
+				messageEndIndex = len(member.messages)
+			}
+
+			messageRefs := make([]MessageRef, 0, messageEndIndex-messageStartIndex)
+
+			for _, message := range member.messages[messageStartIndex:messageEndIndex] {
+				messageRefs = append(messageRefs, *messageRef(message))
+			}
+
+			pages = append(pages, MemberPageArgs{
+				SiteArgs: site,
+				Meta: PageMetaArgs{
+					Title:       fmt.Sprintf("%s - %s", summary.Name, site.Title),
+					Description: description,
+					Canonical:   paginationArgs.CurrentCanonical,
+					Type:        "profile",
+					Next:        paginationArgs.Next,
+					Prev:        paginationArgs.Prev,
+					Feed:        pageFeedLink(config.Feeds.PerMember, fmt.Sprintf("%s - %s", summary.Name, site.Title), summary.Permalink, config),
+				},
+				Member:        summary,
+				PostsPerYear:  postsPerYear,
+				MaxYearCount:  maxYearCount,
+				TopicsStarted: topicsByStarter[summary.Name],
+				RepliedTo:     repliedTo,
+				Messages:      messageRefs,
+				Pagination:    paginationArgs,
+			})
+		}
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return strings.ToLower(summaries[i].Name) < strings.ToLower(summaries[j].Name)
+	})
+
+	index := MemberIndexArgs{
+		SiteArgs: site,
+		Meta: PageMetaArgs{
+			Title:       fmt.Sprintf("Members - %s", site.Title),
+			Description: site.Description,
+			Canonical:   canonicalUrl(config.BaseUrl, memberIndexPath()),
+			Type:        "website",
+		},
+		Members: summaries,
+	}
+
+	return index, pages
+}
@@ -0,0 +1,128 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const messageDirName = "message"
+
+// The number of runes to truncate the description of a message page to. Sites
+// which show link previews generally cut descriptions off at around this
+// length anyway.
+const messageDescriptionTruncateLen = 200
+
+// truncateMessageDescription collapses the whitespace in the text of a message
+// and truncates it on a word boundary so it can be used as the description of
+// the message's page.
+func truncateMessageDescription(text string) string {
+	var (
+		builder strings.Builder
+		length  int
+	)
+
+	for _, word := range strings.Fields(text) {
+		wordLength := utf8.RuneCountInString(word)
+
+		if length > 0 && length+1+wordLength > messageDescriptionTruncateLen {
+			builder.WriteString("…")
+			break
+		}
+
+		if length > 0 {
+			builder.WriteString(" ")
+			length++
+		}
+
+		builder.WriteString(word)
+		length += wordLength
+	}
+
+	return builder.String()
+}
+
+func messageRef(message MessageArgs) *MessageRef {
+	return &MessageRef{
+		Index:             message.Index,
+		Permalink:         message.Permalink,
+		User:              message.User,
+		Title:             message.Title,
+		Timestamp:         message.Timestamp,
+		FormattedDatetime: message.FormattedDatetime,
+		Summary:           message.Summary,
+	}
+}
+
+// messageHeadline returns the subject of a message, or a description of it when
+// it doesn't have one, for titles which need to say what a message is.
+func messageHeadline(message MessageArgs) string {
+	if message.Title != "" {
+		return message.Title
+	}
+
+	return fmt.Sprintf("Message %s from %s", message.Number, message.User)
+}
+
+// messagePageTitle returns the title of the page of a message.
+func messagePageTitle(message MessageArgs, siteTitle string) string {
+	return fmt.Sprintf("%s - %s", messageHeadline(message), siteTitle)
+}
+
+func buildMessagePageArgs(messages []MessageArgs, config OutputConfig) []MessagePageArgs {
+	site := config.siteArgs()
+
+	repliesByParent := make(map[int][]MessageArgs)
+
+	for _, message := range messages {
+		if message.Parent != nil {
+			repliesByParent[message.Parent.Index] = append(repliesByParent[message.Parent.Index], message)
+		}
+	}
+
+	args := make([]MessagePageArgs, len(messages))
+
+	for messageIndex, message := range messages {
+		description := message.Summary
+		if description == "" {
+			description = site.Description
+		}
+
+		pageArgs := MessagePageArgs{
+			SiteArgs: site,
+			Meta: PageMetaArgs{
+				Title:       messagePageTitle(message, site.Title),
+				Description: description,
+				Canonical:   canonicalUrl(config.BaseUrl, message.Permalink),
+				Type:        "article",
+				Published:   message.Timestamp,
+			},
+			Message: message,
+			Replies: repliesByParent[message.Index],
+		}
+
+		if config.publishesStructuredData() {
+			structuredData := messageStructuredData(messages, message, config)
+			pageArgs.StructuredData = &structuredData
+		}
+
+		if message.Parent != nil {
+			parent := messages[message.Parent.Index-1]
+			pageArgs.Parent = &parent
+		}
+
+		if messageIndex > 0 {
+			pageArgs.Prev = messageRef(messages[messageIndex-1])
+			pageArgs.Meta.Prev = &pageArgs.Prev.Permalink
+		}
+
+		if messageIndex < len(messages)-1 {
+			pageArgs.Next = messageRef(messages[messageIndex+1])
+			pageArgs.Meta.Next = &pageArgs.Next.Permalink
+		}
+
+		args[messageIndex] = pageArgs
+	}
+
+	return args
+}
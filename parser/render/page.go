@@ -0,0 +1,200 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"github.com/acearchive/yahoo-groups-reader/parse"
+	"time"
+)
+
+var ErrDuplicatePage = errors.New("two pages have the same path")
+
+// Page is a single page of the site, rendered with the template of the given
+// name to `index.html` in the directory at its path.
+type Page struct {
+	Path     PagePath
+	Template string
+	Args     interface{}
+
+	// When the most recent message on the page was sent, which is zero for
+	// pages which don't show any messages.
+	LastModified time.Time
+
+	// Whether the page is left out of the sitemap, like the pages which just
+	// redirect elsewhere.
+	isUnlisted bool
+}
+
+// newestDate returns the date of the most recent of the messages with the
+// given indices.
+func newestDate(messages []MessageArgs, indices ...int) time.Time {
+	var newest time.Time
+
+	for _, index := range indices {
+		if date := messages[index-1].date; date.After(newest) {
+			newest = date
+		}
+	}
+
+	return newest
+}
+
+func indicesOfMessages(messages []MessageArgs) []int {
+	indices := make([]int, len(messages))
+
+	for i, message := range messages {
+		indices[i] = message.Index
+	}
+
+	return indices
+}
+
+func indicesOfRefs(refs []MessageRef) []int {
+	indices := make([]int, len(refs))
+
+	for i, ref := range refs {
+		indices[i] = ref.Index
+	}
+
+	return indices
+}
+
+// BuildPages builds the template arguments of every page of the site.
+func BuildPages(thread parse.MessageThread, config OutputConfig) ([]Page, error) {
+	manifest, err := ReadManifest(config.ManifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	built := buildThread(thread, config, manifest)
+
+	pages, _, err := buildPages(built, config)
+
+	return pages, err
+}
+
+// buildPages returns every page of the site, along with the redirects from
+// thread pages in the manifest which no longer exist.
+func buildPages(built builtThread, config OutputConfig) ([]Page, []redirect, error) {
+	messages, topics := built.messages, built.topics
+	config = config.withArchiveSpan(messages)
+
+	newestOverall := newestDate(messages, indicesOfMessages(messages)...)
+	newestByYear := make(map[int]time.Time)
+
+	for _, message := range messages {
+		if year := message.date.Year(); message.date.After(newestByYear[year]) {
+			newestByYear[year] = message.date
+		}
+	}
+
+	var pages []Page
+
+	for _, args := range buildThreadArgs(messages, built.pageStarts, config) {
+		pages = append(pages, Page{
+			Path:         args.Pagination.Current,
+			Template:     threadTemplateName,
+			Args:         args,
+			LastModified: newestDate(messages, indicesOfMessages(args.Messages)...),
+		})
+	}
+
+	for _, args := range buildMessagePageArgs(messages, config) {
+		pages = append(pages, Page{
+			Path:         args.Message.Permalink,
+			Template:     messageTemplateName,
+			Args:         args,
+			LastModified: newestDate(messages, append(indicesOfMessages(args.Replies), args.Message.Index)...),
+		})
+	}
+
+	topicIndex, topicPages := buildTopicArgs(topics, messages, config)
+
+	pages = append(pages, Page{
+		Path:         topicIndexPath(),
+		Template:     topicIndexTemplateName,
+		Args:         topicIndex,
+		LastModified: newestOverall,
+	})
+
+	for _, args := range topicPages {
+		pages = append(pages, Page{
+			Path:         args.Topic.Permalink,
+			Template:     topicTemplateName,
+			Args:         args,
+			LastModified: newestDate(messages, args.Topic.lastActivityIndex),
+		})
+	}
+
+	memberIndex, memberPages := buildMemberArgs(messages, topicPages, config)
+
+	pages = append(pages, Page{
+		Path:         memberIndexPath(),
+		Template:     memberIndexTemplateName,
+		Args:         memberIndex,
+		LastModified: newestOverall,
+	})
+
+	for _, args := range memberPages {
+		pages = append(pages, Page{
+			Path:         args.Pagination.Current,
+			Template:     memberTemplateName,
+			Args:         args,
+			LastModified: newestDate(messages, indicesOfRefs(args.Messages)...),
+		})
+	}
+
+	archiveIndex, yearPages, monthPages := buildArchiveArgs(messages, topicPages, config)
+
+	pages = append(pages, Page{
+		Path:         archiveIndexPath(),
+		Template:     archiveIndexTemplateName,
+		Args:         archiveIndex,
+		LastModified: newestOverall,
+	})
+
+	for _, args := range yearPages {
+		pages = append(pages, Page{
+			Path:         args.Year.Path,
+			Template:     yearTemplateName,
+			Args:         args,
+			LastModified: newestByYear[args.Year.Year],
+		})
+	}
+
+	for _, args := range monthPages {
+		pages = append(pages, Page{
+			Path:         args.Month.Path,
+			Template:     monthTemplateName,
+			Args:         args,
+			LastModified: newestDate(messages, indicesOfRefs(args.Messages)...),
+		})
+	}
+
+	// The paths of the different kinds of pages can overlap. For example, a
+	// thread with thousands of pages has page numbers which look like years.
+	pagePaths := make(map[PagePath]struct{}, len(pages))
+
+	for _, page := range pages {
+		if _, exists := pagePaths[page.Path]; exists {
+			return nil, nil, fmt.Errorf("%w: %s", ErrDuplicatePage, page.Path)
+		}
+
+		pagePaths[page.Path] = struct{}{}
+	}
+
+	// Redirects are only built for paths which aren't pages, so these can't
+	// overlap with the other pages.
+	redirects := buildRedirects(built, pagePaths)
+
+	for _, args := range buildRedirectPageArgs(redirects, config) {
+		pages = append(pages, Page{
+			Path:       args.From,
+			Template:   redirectTemplateName,
+			Args:       args,
+			isUnlisted: true,
+		})
+	}
+
+	return pages, redirects, nil
+}
@@ -0,0 +1,184 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidPaginationStrategy = errors.New("invalid pagination strategy")
+
+// The names of the pagination strategies which can be chosen from the CLI.
+const (
+	PaginateFixed = "fixed"
+	PaginateMonth = "month"
+	PaginateTopic = "topic"
+	PaginateSize  = "size"
+)
+
+// PaginationStrategy splits the messages in the thread into pages.
+type PaginationStrategy interface {
+	// PageStarts returns the index of the first message on each page, given the
+	// messages sorted by date. The first page always starts at 0.
+	PageStarts(messages []MessageArgs) []int
+}
+
+// FixedSizePagination puts the same number of messages on each page, except
+// the last.
+type FixedSizePagination struct {
+	PageSize int
+}
+
+func (p FixedSizePagination) PageStarts(messages []MessageArgs) []int {
+	var starts []int
+
+	for start := 0; start < len(messages); start += p.PageSize {
+		starts = append(starts, start)
+	}
+
+	return starts
+}
+
+// MonthPagination puts the messages sent in each calendar month on their own
+// page. Months are in UTC, since messages are sorted by the instant they were
+// sent, and months in the time zone of each message wouldn't be contiguous.
+type MonthPagination struct{}
+
+func (p MonthPagination) PageStarts(messages []MessageArgs) []int {
+	var starts []int
+
+	for i, message := range messages {
+		if i == 0 {
+			starts = append(starts, i)
+			continue
+		}
+
+		prevYear, prevMonth, _ := messages[i-1].date.UTC().Date()
+		year, month, _ := message.date.UTC().Date()
+
+		if year != prevYear || month != prevMonth {
+			starts = append(starts, i)
+		}
+	}
+
+	return starts
+}
+
+// TopicPagination starts a new page once a page has at least `MinPageSize`
+// messages, but only between messages where no topic is split across the
+// pages. Topics overlap in time, so pages can be much longer than
+// `MinPageSize` in busy archives.
+type TopicPagination struct {
+	MinPageSize int
+}
+
+func (p TopicPagination) PageStarts(messages []MessageArgs) []int {
+	lastMessageInTopic := make(map[PagePath]int)
+
+	for i, message := range messages {
+		lastMessageInTopic[message.TopicPermalink] = i
+	}
+
+	var (
+		starts      []int
+		pageStart   int
+		lastInPages int
+	)
+
+	for i, message := range messages {
+		if i == 0 {
+			starts = append(starts, i)
+		} else if i-pageStart >= p.MinPageSize && lastInPages < i {
+			starts = append(starts, i)
+			pageStart = i
+		}
+
+		if last := lastMessageInTopic[message.TopicPermalink]; last > lastInPages {
+			lastInPages = last
+		}
+	}
+
+	return starts
+}
+
+// ByteSizePagination starts a new page before a message which would make the
+// rendered messages on the page larger than `TargetBytes`. A message which is
+// larger than that on its own gets its own page.
+type ByteSizePagination struct {
+	TargetBytes int
+}
+
+func messageSize(message MessageArgs) int {
+	size := len(message.Body)
+
+	if message.Parent != nil {
+		size += len(message.Parent.Body)
+	}
+
+	return size
+}
+
+func (p ByteSizePagination) PageStarts(messages []MessageArgs) []int {
+	var (
+		starts   []int
+		pageSize int
+	)
+
+	for i, message := range messages {
+		size := messageSize(message)
+
+		if i == 0 || pageSize+size > p.TargetBytes {
+			starts = append(starts, i)
+			pageSize = 0
+		}
+
+		pageSize += size
+	}
+
+	return starts
+}
+
+// ParsePaginationStrategy returns the pagination strategy with the given name.
+func ParsePaginationStrategy(name string, pageSize, pageBytes int) (PaginationStrategy, error) {
+	// The page size is checked for every strategy, since it's also the number
+	// of messages on each page of a member's messages.
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("%w: the page size must be positive: %d", ErrInvalidPaginationStrategy, pageSize)
+	}
+
+	if name == PaginateSize && pageBytes <= 0 {
+		return nil, fmt.Errorf("%w: the page size in bytes must be positive: %d", ErrInvalidPaginationStrategy, pageBytes)
+	}
+
+	switch name {
+	case PaginateFixed:
+		return FixedSizePagination{PageSize: pageSize}, nil
+	case PaginateMonth:
+		return MonthPagination{}, nil
+	case PaginateTopic:
+		return TopicPagination{MinPageSize: pageSize}, nil
+	case PaginateSize:
+		return ByteSizePagination{TargetBytes: pageBytes}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPaginationStrategy, name)
+	}
+}
+
+// paginate sets the page each message is on, and returns the index of the
+// first message on each page.
+func paginate(messages []MessageArgs, strategy PaginationStrategy) []int {
+	starts := strategy.PageStarts(messages)
+
+	for pageIndex, start := range starts {
+		end := len(messages)
+		if pageIndex+1 < len(starts) {
+			end = starts[pageIndex+1]
+		}
+
+		for messageIndex := start; messageIndex < end; messageIndex++ {
+			messages[messageIndex].pageNumber = pageIndex + firstPageNumber
+			messages[messageIndex].PagePath = pagePath(pageIndex + firstPageNumber)
+		}
+	}
+
+	return starts
+}
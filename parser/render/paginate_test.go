@@ -0,0 +1,85 @@
+package render
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMonthPagination(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tokyo := time.FixedZone("JST", 9*60*60)
+
+	tests := []struct {
+		name  string
+		dates []time.Time
+		want  []int
+	}{
+		{
+			name:  "no messages",
+			dates: nil,
+			want:  nil,
+		},
+		{
+			name: "one month",
+			dates: []time.Time{
+				time.Date(2003, time.January, 6, 12, 0, 0, 0, time.UTC),
+				time.Date(2003, time.January, 20, 12, 0, 0, 0, time.UTC),
+			},
+			want: []int{0},
+		},
+		{
+			name: "several months",
+			dates: []time.Time{
+				time.Date(2003, time.January, 6, 12, 0, 0, 0, time.UTC),
+				time.Date(2003, time.February, 3, 12, 0, 0, 0, time.UTC),
+				time.Date(2003, time.February, 4, 12, 0, 0, 0, time.UTC),
+				time.Date(2004, time.February, 4, 12, 0, 0, 0, time.UTC),
+			},
+			want: []int{0, 1, 3},
+		},
+		{
+			name: "mixed time zones",
+			dates: []time.Time{
+				// 31 January at 23:00 UTC, but already February in Tokyo.
+				time.Date(2003, time.February, 1, 8, 0, 0, 0, tokyo),
+				// 31 January at 23:30 UTC, and still January in New York.
+				time.Date(2003, time.January, 31, 18, 30, 0, 0, newYork),
+				time.Date(2003, time.February, 1, 0, 30, 0, 0, time.UTC),
+			},
+			want: []int{0, 2},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messages := make([]MessageArgs, len(test.dates))
+			for i, date := range test.dates {
+				messages[i] = MessageArgs{date: date}
+			}
+
+			if got := (MonthPagination{}).PageStarts(messages); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestByteSizePagination(t *testing.T) {
+	messages := []MessageArgs{
+		{Body: "aaaa", Text: "the original text, which isn't shown on the page"},
+		{Body: "bbbb"},
+		{Body: "cc", Parent: &ParentArgs{Body: "dd"}},
+		{Body: "eeeeeeeeee"},
+	}
+
+	want := []int{0, 2, 3}
+
+	if got := (ByteSizePagination{TargetBytes: 8}).PageStarts(messages); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
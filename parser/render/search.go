@@ -3,6 +3,7 @@ package render
 import (
 	"encoding/json"
 	"github.com/acearchive/yahoo-groups-reader/body"
+	"github.com/acearchive/yahoo-groups-reader/logger"
 	"github.com/acearchive/yahoo-groups-reader/parse"
 	"os"
 	"path/filepath"
@@ -22,6 +23,7 @@ const messageSummaryTruncateLen = 400
 type MessageSearchFields struct {
 	Index      int    `json:"id"`
 	PageNumber int    `json:"page"`
+	Url        string `json:"url"`
 	Timestamp  string `json:"timestamp"`
 	User       string `json:"user"`
 	Flair      string `json:"flair"`
@@ -31,27 +33,46 @@ type MessageSearchFields struct {
 	Summary    string `json:"summary"`
 }
 
-func pageNumberOfMessage(index, pageSize int) int {
-	return calculateTotalPages(index, pageSize)
+// searchTextVisitor collects the text of a message body written by the sender,
+// skipping quoted and forwarded text.
+type searchTextVisitor struct {
+	builder strings.Builder
 }
 
-func tokensToSearchText(tokens []body.Token) string {
+func (v *searchTextVisitor) Enter(node body.Node) bool {
+	switch concreteNode := node.(type) {
+	case *body.Quote, *body.CollapsedQuote, *body.ForwardedSection:
+		return false
+	case *body.Text:
+		v.builder.WriteString(concreteNode.Content)
+	}
+
+	return true
+}
+
+func (v *searchTextVisitor) Leave(node body.Node) {
+	if _, isParagraph := node.(*body.Paragraph); isParagraph {
+		v.builder.WriteString("\n")
+	}
+}
+
+// flatSearchText collects the text of a message body written by the sender
+// without building a document tree, for when the tokens aren't balanced.
+func flatSearchText(tokens []body.Token) string {
 	var builder strings.Builder
 
-	quoteLevel := 0
+	depth := 0
 
 	for _, token := range tokens {
 		switch concreteToken := token.(type) {
-		case body.StartQuoteToken:
-			quoteLevel++
-		case body.EndQuoteToken:
-			quoteLevel--
+		case body.StartQuoteToken, body.StartCollapsedQuoteToken, body.StartForwardedToken:
+			depth++
+		case body.EndQuoteToken, body.EndCollapsedQuoteToken, body.EndForwardedToken:
+			depth--
 		case body.TextToken:
-			if quoteLevel > 0 {
-				continue
+			if depth == 0 {
+				builder.WriteString(string(concreteToken))
 			}
-
-			builder.WriteString(string(concreteToken))
 		case body.EndParagraphToken:
 			builder.WriteString("\n")
 		}
@@ -60,6 +81,19 @@ func tokensToSearchText(tokens []body.Token) string {
 	return builder.String()
 }
 
+func messageSearchText(message parse.Message) string {
+	document, err := body.NewDocument(message.Body.Tokens)
+	if err != nil {
+		logger.Verbose.Printf("%v, so the search text will be taken from the tokens as-is: '%s'", err, message.ID)
+		return flatSearchText(message.Body.Tokens)
+	}
+
+	visitor := &searchTextVisitor{}
+	body.Walk(document, visitor)
+
+	return visitor.builder.String()
+}
+
 func truncateString(text string, length int) string {
 	if length >= len(text) {
 		return text
@@ -77,17 +111,20 @@ func truncateMessageSummary(text string) string {
 	return truncateString(text, messageSummaryTruncateLen)
 }
 
-func buildSearchFields(thread parse.MessageThread, config OutputConfig) []MessageSearchFields {
+// buildSearchFields builds the search index fields of each message. The
+// messages are the thread sorted by date, once they've been split into pages.
+func buildSearchFields(thread parse.MessageThread, messages []MessageArgs) []MessageSearchFields {
 	fields := make([]MessageSearchFields, 0, len(thread))
 
 	sortedMessages, _ := thread.SortedByDate()
 
 	for i, message := range sortedMessages {
-		messageBody := tokensToSearchText(message.Body.Tokens)
+		messageBody := messageSearchText(message)
 
 		field := MessageSearchFields{
-			Index:      i + 1,
-			PageNumber: pageNumberOfMessage(i+1, config.PageSize),
+			Index:      messages[i].PermalinkNumber,
+			PageNumber: messages[i].pageNumber,
+			Url:        string(messages[i].Permalink),
 			Timestamp:  message.Date.Format(time.RFC3339),
 			User:       message.User,
 			Flair:      message.Flair,
@@ -106,8 +143,8 @@ func buildSearchFields(thread parse.MessageThread, config OutputConfig) []Messag
 	return fields
 }
 
-func writeSearchData(thread parse.MessageThread, config OutputConfig, path string) error {
-	fields := buildSearchFields(thread, config)
+func writeSearchData(thread parse.MessageThread, messages []MessageArgs, path string) error {
+	fields := buildSearchFields(thread, messages)
 
 	jsonFile, err := os.OpenFile(filepath.Join(path, searchFileName), os.O_CREATE|os.O_EXCL|os.O_WRONLY, outputFileMode)
 	if err != nil {
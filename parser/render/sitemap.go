@@ -0,0 +1,131 @@
+package render
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/acearchive/yahoo-groups-reader/logger"
+	"path/filepath"
+	"time"
+)
+
+const sitemapFileName = "sitemap.xml"
+
+// The file the absolute URL of the sitemap is written to, which the asset
+// pipeline links to from `robots.txt`. This isn't published.
+const sitemapUrlFileName = "sitemap-url.txt"
+
+// The most URLs a sitemap can list. Past this, the URLs are split across
+// several sitemaps which are listed in a sitemap index.
+//
+// https://www.sitemaps.org/protocol.html
+const sitemapUrlLimit = 50000
+
+const sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type sitemapUrl struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapUrlSet struct {
+	XMLName   xml.Name     `xml:"urlset"`
+	Namespace string       `xml:"xmlns,attr"`
+	Urls      []sitemapUrl `xml:"url"`
+}
+
+type sitemapRef struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName   xml.Name     `xml:"sitemapindex"`
+	Namespace string       `xml:"xmlns,attr"`
+	Sitemaps  []sitemapRef `xml:"sitemap"`
+}
+
+func sitemapPartFileName(number int) string {
+	return fmt.Sprintf("sitemap-%d.xml", number)
+}
+
+func formatLastMod(date time.Time) string {
+	if date.IsZero() {
+		return ""
+	}
+
+	return date.Format(time.RFC3339)
+}
+
+// writeSitemap writes a sitemap of every page of the site, or a sitemap index
+// and several sitemaps if there are too many pages for one.
+func writeSitemap(pages []Page, config OutputConfig, path string) error {
+	if !isAbsoluteUrl(config.BaseUrl) {
+		logger.Verbose.Printf("%v, so no sitemap will be written: '%s'", ErrRelativeBaseUrl, config.BaseUrl)
+		return nil
+	}
+
+	sitemapLoc := canonicalUrl(config.BaseUrl, PagePath("/"+sitemapFileName))
+
+	if err := writeOutputFile(filepath.Join(path, sitemapUrlFileName), string(sitemapLoc)+"\n"); err != nil {
+		return err
+	}
+
+	var (
+		urls     []sitemapUrl
+		newest   []time.Time
+		partDate time.Time
+	)
+
+	for _, page := range pages {
+		if page.isUnlisted {
+			continue
+		}
+
+		urls = append(urls, sitemapUrl{
+			Loc:     string(canonicalUrl(config.BaseUrl, page.Path)),
+			LastMod: formatLastMod(page.LastModified),
+		})
+
+		// Keep track of the most recent change in each part of the sitemap for
+		// the sitemap index.
+		if page.LastModified.After(partDate) {
+			partDate = page.LastModified
+		}
+
+		if len(urls)%sitemapUrlLimit == 0 {
+			newest = append(newest, partDate)
+			partDate = time.Time{}
+		}
+	}
+
+	if len(urls)%sitemapUrlLimit != 0 {
+		newest = append(newest, partDate)
+	}
+
+	if len(urls) <= sitemapUrlLimit {
+		return writeXmlFile(filepath.Join(path, sitemapFileName), sitemapUrlSet{Namespace: sitemapNamespace, Urls: urls})
+	}
+
+	index := sitemapIndex{Namespace: sitemapNamespace}
+
+	for partIndex := range newest {
+		start := partIndex * sitemapUrlLimit
+		end := start + sitemapUrlLimit
+		if end > len(urls) {
+			end = len(urls)
+		}
+
+		fileName := sitemapPartFileName(partIndex + 1)
+
+		if err := writeXmlFile(filepath.Join(path, fileName), sitemapUrlSet{Namespace: sitemapNamespace, Urls: urls[start:end]}); err != nil {
+			return err
+		}
+
+		index.Sitemaps = append(index.Sitemaps, sitemapRef{
+			Loc:     string(canonicalUrl(config.BaseUrl, PagePath("/"+fileName))),
+			LastMod: formatLastMod(newest[partIndex]),
+		})
+	}
+
+	return writeXmlFile(filepath.Join(path, sitemapFileName), index)
+}
@@ -0,0 +1,210 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/acearchive/yahoo-groups-reader/parse"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const sourceDirName = "source"
+
+var ErrMalformedSource = errors.New("malformed email source")
+
+// DefaultSourceHeaders are the headers kept in published email sources by
+// default. These are enough to decode the message without publishing routing
+// information or profile data.
+var DefaultSourceHeaders = []string{
+	"Message-ID",
+	"In-Reply-To",
+	"References",
+	"Date",
+	"From",
+	"To",
+	"Cc",
+	"Subject",
+	"MIME-Version",
+	"Content-Type",
+	"Content-Transfer-Encoding",
+}
+
+// messageIDHeaders are the headers which contain message IDs. Message IDs look
+// like email addresses but aren't, and they're needed to thread messages, so
+// they aren't redacted like addresses.
+var messageIDHeaders = []string{
+	"Message-ID",
+	"In-Reply-To",
+	"References",
+}
+
+const redactedText = "[redacted]"
+
+var (
+	sourceEmailAddressRegex = regexp.MustCompile(`[\w.!#$%&'*+/=?^{|}~-]+@[\w.-]*\w|[\w.!#$%&'*+/=?^{|}~-]+@\.\.\.`)
+	sourceIPv4Regex         = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	sourceIPv6Regex         = regexp.MustCompile(`\b(?:[0-9A-Fa-f]{1,4}:){7}[0-9A-Fa-f]{1,4}\b|\b(?:[0-9A-Fa-f]{1,4}:)*[0-9A-Fa-f]{0,4}::(?:[0-9A-Fa-f]{1,4}:?)*\b`)
+)
+
+// SourceConfig determines whether the original email sources are published
+// and which headers are kept. Email addresses and IP addresses are redacted
+// from the headers which are kept, but the body of each email is published
+// unredacted. This includes the parts of the body which aren't shown on the
+// site, like HTML alternatives and the headers of attached messages.
+type SourceConfig struct {
+	Publish        bool
+	AllowedHeaders []string
+}
+
+func (c SourceConfig) isAllowed(name string) bool {
+	for _, allowed := range c.AllowedHeaders {
+		if strings.EqualFold(allowed, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sourceHref returns the URL of the published source of a message, or an
+// empty string if it isn't published. The file name of the source is used so
+// that the URL doesn't change as messages are added to the archive.
+func sourceHref(message parse.Message, config SourceConfig) string {
+	if !config.Publish || message.SourcePath == "" {
+		return ""
+	}
+
+	return path.Join("/", sourceDirName, url.PathEscape(filepath.Base(message.SourcePath)))
+}
+
+// splitHeader splits an email into its header fields, including any folded
+// continuation lines, and its body, which starts after the blank line.
+func splitHeader(source []byte) (fields []string, lineEnding string, body []byte, err error) {
+	lineEnding = "\n"
+	if bytes.Contains(source, []byte("\r\n")) {
+		lineEnding = "\r\n"
+	}
+
+	separator := []byte(lineEnding + lineEnding)
+
+	headerEnd := bytes.Index(source, separator)
+	if headerEnd < 0 {
+		return nil, "", nil, fmt.Errorf("%w: missing end of header", ErrMalformedSource)
+	}
+
+	for _, line := range strings.Split(string(source[:headerEnd]), lineEnding) {
+		isContinuation := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+
+		switch {
+		case isContinuation && len(fields) > 0:
+			fields[len(fields)-1] += lineEnding + line
+		case isContinuation:
+			return nil, "", nil, fmt.Errorf("%w: header starts with a continuation line", ErrMalformedSource)
+		default:
+			fields = append(fields, line)
+		}
+	}
+
+	return fields, lineEnding, source[headerEnd+len(separator):], nil
+}
+
+func isMessageIDHeader(name string) bool {
+	for _, header := range messageIDHeaders {
+		if strings.EqualFold(header, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redactHeaderValue redacts IP addresses and email addresses from the value of
+// the header `name`. Email addresses are redacted from every header except the
+// ones which contain message IDs.
+func redactHeaderValue(name, value string) string {
+	value = sourceIPv4Regex.ReplaceAllString(value, redactedText)
+	value = sourceIPv6Regex.ReplaceAllStringFunc(value, func(match string) string {
+		// Don't mistake a time like "12:00:00" for an IPv6 address.
+		if strings.Contains(match, "::") || strings.Count(match, ":") == 7 {
+			return redactedText
+		}
+
+		return match
+	})
+
+	if isMessageIDHeader(name) {
+		return value
+	}
+
+	return sourceEmailAddressRegex.ReplaceAllString(value, redactedText)
+}
+
+// sanitizeSource removes the headers of an email which aren't in the
+// allow-list and redacts email addresses and IP addresses from the rest. The
+// body is left as-is, since it may be encoded in a way that can't be redacted
+// without decoding it.
+func sanitizeSource(source []byte, config SourceConfig) ([]byte, error) {
+	fields, lineEnding, body, err := splitHeader(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var output bytes.Buffer
+
+	for _, field := range fields {
+		separatorIndex := strings.Index(field, ":")
+		if separatorIndex < 0 {
+			continue
+		}
+
+		name, value := field[:separatorIndex], field[separatorIndex+1:]
+		if !config.isAllowed(strings.TrimSpace(name)) {
+			continue
+		}
+
+		output.WriteString(name)
+		output.WriteString(":")
+		output.WriteString(redactHeaderValue(strings.TrimSpace(name), value))
+		output.WriteString(lineEnding)
+	}
+
+	output.WriteString(lineEnding)
+	output.Write(body)
+
+	return output.Bytes(), nil
+}
+
+func writeSources(thread parse.MessageThread, config SourceConfig, outputPath string) error {
+	sourceDirPath := filepath.Join(outputPath, sourceDirName)
+
+	if err := os.Mkdir(sourceDirPath, outputDirMode); err != nil {
+		return err
+	}
+
+	for _, message := range thread {
+		if message.SourcePath == "" {
+			continue
+		}
+
+		source, err := os.ReadFile(message.SourcePath)
+		if err != nil {
+			return err
+		}
+
+		sanitized, err := sanitizeSource(source, config)
+		if err != nil {
+			return fmt.Errorf("%w: '%s'", err, message.SourcePath)
+		}
+
+		if err := os.WriteFile(filepath.Join(sourceDirPath, filepath.Base(message.SourcePath)), sanitized, outputFileMode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package render
+
+import "testing"
+
+func TestRedactHeaderValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   string
+	}{
+		{name: "address", header: "From", value: " Jane Doe <jane@example.com>", want: " Jane Doe <[redacted]>"},
+		{name: "truncated address", header: "To", value: " jane@...", want: " [redacted]"},
+		{name: "several addresses", header: "Cc", value: " a@example.com, b@example.org", want: " [redacted], [redacted]"},
+		{name: "IPv4 address", header: "Subject", value: " Banned 192.168.1.1", want: " Banned [redacted]"},
+		{name: "IPv6 address", header: "Subject", value: " Banned fe80::1", want: " Banned [redacted]"},
+		{name: "time", header: "Date", value: " Mon, 6 Jan 2003 12:00:00 +0000", want: " Mon, 6 Jan 2003 12:00:00 +0000"},
+		{name: "message ID", header: "Message-ID", value: " <1234.abcd@example.com>", want: " <1234.abcd@example.com>"},
+		{name: "message ID case", header: "in-reply-to", value: " <1234.abcd@example.com>", want: " <1234.abcd@example.com>"},
+		{name: "references", header: "References", value: " <a@example.com> <b@example.com>", want: " <a@example.com> <b@example.com>"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := redactHeaderValue(test.header, test.value); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
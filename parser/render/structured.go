@@ -0,0 +1,167 @@
+package render
+
+import (
+	"fmt"
+	"time"
+)
+
+// The structured data on each page describes the messages and the archive
+// using the schema.org vocabulary as JSON-LD, which search engines and
+// archival aggregators understand.
+//
+// https://schema.org/DiscussionForumPosting
+
+const schemaOrgContext = "https://schema.org"
+
+const (
+	schemaTypePosting    = "DiscussionForumPosting"
+	schemaTypeComment    = "Comment"
+	schemaTypeCollection = "Collection"
+	schemaTypePerson     = "Person"
+)
+
+// The fragment of the URL of the first page which identifies the archive as a
+// whole, as opposed to the page itself.
+const archiveNodeFragment = "#archive"
+
+type PersonData struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+	Url  string `json:"url,omitempty"`
+}
+
+// NodeRef refers to something described elsewhere by its `@id`.
+type NodeRef struct {
+	Type string `json:"@type"`
+	Id   string `json:"@id"`
+}
+
+type MessageData struct {
+	Context       string     `json:"@context,omitempty"`
+	Type          string     `json:"@type"`
+	Id            string     `json:"@id"`
+	Url           string     `json:"url"`
+	Headline      string     `json:"headline"`
+	Text          string     `json:"text"`
+	DatePublished string     `json:"datePublished"`
+	Author        PersonData `json:"author"`
+	IsPartOf      NodeRef    `json:"isPartOf"`
+	ParentItem    *NodeRef   `json:"parentItem,omitempty"`
+}
+
+type ArchiveData struct {
+	Context          string `json:"@context"`
+	Type             string `json:"@type"`
+	Id               string `json:"@id"`
+	Url              string `json:"url"`
+	Name             string `json:"name"`
+	Description      string `json:"description"`
+	InLanguage       string `json:"inLanguage"`
+	TemporalCoverage string `json:"temporalCoverage,omitempty"`
+	CollectionSize   int    `json:"collectionSize"`
+}
+
+// archiveSpan is the dates of the first and last messages in the archive.
+type archiveSpan struct {
+	first time.Time
+	last  time.Time
+	count int
+}
+
+func newArchiveSpan(messages []MessageArgs) archiveSpan {
+	if len(messages) == 0 {
+		return archiveSpan{}
+	}
+
+	return archiveSpan{
+		first: messages[0].date,
+		last:  messages[len(messages)-1].date,
+		count: len(messages),
+	}
+}
+
+// temporalCoverage returns the span of the archive as an ISO 8601 interval of
+// dates.
+func (s archiveSpan) temporalCoverage() string {
+	if s.count == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%s/%s", s.first.Format("2006-01-02"), s.last.Format("2006-01-02"))
+}
+
+// withArchiveSpan returns the config with the span of the archive set, which
+// is needed to describe the archive on every page.
+func (c OutputConfig) withArchiveSpan(messages []MessageArgs) OutputConfig {
+	c.archiveSpan = newArchiveSpan(messages)
+	return c
+}
+
+func archiveNodeId(baseUrl string) string {
+	return fmt.Sprintf("%s%s", canonicalUrl(baseUrl, "/"), archiveNodeFragment)
+}
+
+// publishesStructuredData returns whether pages include structured data, which
+// needs absolute URLs to identify each message and the archive.
+func (c OutputConfig) publishesStructuredData() bool {
+	return isAbsoluteUrl(c.BaseUrl)
+}
+
+// archiveStructuredData returns the structured data describing the archive, or
+// nil if pages don't include structured data.
+func (c OutputConfig) archiveStructuredData() *ArchiveData {
+	if !c.publishesStructuredData() {
+		return nil
+	}
+
+	return &ArchiveData{
+		Context:          schemaOrgContext,
+		Type:             schemaTypeCollection,
+		Id:               archiveNodeId(c.BaseUrl),
+		Url:              string(canonicalUrl(c.BaseUrl, "/")),
+		Name:             c.Title,
+		Description:      c.Description(),
+		InLanguage:       c.Lang(),
+		TemporalCoverage: c.archiveSpan.temporalCoverage(),
+		CollectionSize:   c.archiveSpan.count,
+	}
+}
+
+// messageSchemaType returns the type of a message, which is a posting if it
+// starts a conversation and a comment if it replies to another message.
+func messageSchemaType(message MessageArgs) string {
+	if message.Parent == nil {
+		return schemaTypePosting
+	}
+
+	return schemaTypeComment
+}
+
+func messageStructuredData(messages []MessageArgs, message MessageArgs, config OutputConfig) MessageData {
+	permalink := string(canonicalUrl(config.BaseUrl, message.Permalink))
+
+	data := MessageData{
+		Context:       schemaOrgContext,
+		Type:          messageSchemaType(message),
+		Id:            permalink,
+		Url:           permalink,
+		Headline:      messageHeadline(message),
+		Text:          message.Text,
+		DatePublished: message.Timestamp,
+		Author: PersonData{
+			Type: schemaTypePerson,
+			Name: message.User,
+			Url:  string(canonicalUrl(config.BaseUrl, message.UserHref)),
+		},
+		IsPartOf: NodeRef{Type: schemaTypeCollection, Id: archiveNodeId(config.BaseUrl)},
+	}
+
+	if message.Parent != nil {
+		data.ParentItem = &NodeRef{
+			Type: messageSchemaType(messages[message.Parent.Index-1]),
+			Id:   string(canonicalUrl(config.BaseUrl, message.Parent.Permalink)),
+		}
+	}
+
+	return data
+}
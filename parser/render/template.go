@@ -1,7 +1,7 @@
 package render
 
 import (
-	_ "embed"
+	"embed"
 	"fmt"
 	"github.com/Masterminds/sprig/v3"
 	"html/template"
@@ -12,10 +12,23 @@ const (
 	messageParentBodyIndent = 20
 )
 
-//go:embed template.html.tmpl
-var templateString string
+const (
+	threadTemplateName       = "thread.html.tmpl"
+	messageTemplateName      = "message.html.tmpl"
+	topicTemplateName        = "topic.html.tmpl"
+	topicIndexTemplateName   = "topics.html.tmpl"
+	memberTemplateName       = "member.html.tmpl"
+	memberIndexTemplateName  = "members.html.tmpl"
+	archiveIndexTemplateName = "archive.html.tmpl"
+	yearTemplateName         = "year.html.tmpl"
+	monthTemplateName        = "month.html.tmpl"
+	redirectTemplateName     = "redirect.html.tmpl"
+)
+
+//go:embed *.html.tmpl
+var templateFiles embed.FS
 
-var Template *template.Template
+var Templates *template.Template
 
 func init() {
 	templateFunctions := sprig.FuncMap()
@@ -24,5 +37,5 @@ func init() {
 		return template.HTML(fmt.Sprintf("<!-- %s -->", text))
 	}
 
-	Template = template.Must(template.New("yahoo-groups-reader").Funcs(templateFunctions).Parse(templateString))
+	Templates = template.Must(template.New("yahoo-groups-reader").Funcs(templateFunctions).ParseFS(templateFiles, "*.html.tmpl"))
 }
@@ -0,0 +1,297 @@
+package render
+
+import (
+	"fmt"
+	"github.com/acearchive/yahoo-groups-reader/parse"
+	"sort"
+)
+
+const (
+	topicDirName      = "topic"
+	topicIndexDirName = "topics"
+)
+
+// The deepest level of indentation of replies on a topic page. Replies nested
+// any deeper are shown at this depth so that long conversations don't get
+// squeezed off the side of the page.
+const maxTopicDepth = 6
+
+const untitledTopicTitle = "(no subject)"
+
+// TopicMessageArgs is a message on a topic page, along with how deeply it's
+// nested in the conversation.
+type TopicMessageArgs struct {
+	MessageArgs
+	Depth int
+}
+
+type TopicSummaryArgs struct {
+	Number                int
+	Permalink             PagePath
+	Title                 string
+	Starter               string
+	ReplyCount            int
+	Participants          []string
+	LastActivityTimestamp string
+	LastActivityDatetime  string
+
+	// The index of the most recent message in the topic, which is used to sort
+	// the topics. The timestamps can't be compared since they're not all in the
+	// same time zone.
+	lastActivityIndex int
+}
+
+type TopicIndexArgs struct {
+	SiteArgs
+	Meta   PageMetaArgs
+	Topics []TopicSummaryArgs
+}
+
+type TopicPageArgs struct {
+	SiteArgs
+	Meta           PageMetaArgs
+	Topic          TopicSummaryArgs
+	Messages       []TopicMessageArgs
+	StructuredData []MessageData
+}
+
+// topic is a conversation, which is a message that doesn't reply to anything
+// in the archive along with all its replies, in reply order.
+type topic struct {
+	messages []TopicMessageArgs
+}
+
+type topicNode struct {
+	index    int
+	parent   *topicNode
+	children []*topicNode
+}
+
+func topicPermalink(number int) PagePath {
+	return PagePath(fmt.Sprintf("/%s/%d/", topicDirName, number))
+}
+
+func topicIndexPath() PagePath {
+	return PagePath(fmt.Sprintf("/%s/", topicIndexDirName))
+}
+
+func (n *topicNode) hasAncestor(ancestor *topicNode) bool {
+	for current := n; current != nil; current = current.parent {
+		if current == ancestor {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (n *topicNode) flatten(messages []MessageArgs, depth int, output []TopicMessageArgs) []TopicMessageArgs {
+	displayDepth := depth
+	if displayDepth > maxTopicDepth {
+		displayDepth = maxTopicDepth
+	}
+
+	output = append(output, TopicMessageArgs{MessageArgs: messages[n.index], Depth: displayDepth})
+
+	for _, child := range n.children {
+		output = child.flatten(messages, depth+1, output)
+	}
+
+	return output
+}
+
+// groupTopics groups messages into topics by following the chains of replies
+// between them. Messages which don't reply to anything in the archive but have
+// the same subject as an earlier topic with a reply prefix like "Re:" are
+// considered replies to the message which started that topic. The topic of
+// each message is set on its arguments.
+func groupTopics(messages []MessageArgs) []topic {
+	nodes := make([]*topicNode, len(messages))
+
+	for i := range messages {
+		nodes[i] = &topicNode{index: i}
+	}
+
+	for i, message := range messages {
+		if message.Parent == nil {
+			continue
+		}
+
+		parentNode := nodes[message.Parent.Index-1]
+
+		// Guard against a message which is somehow its own ancestor.
+		if parentNode.hasAncestor(nodes[i]) {
+			continue
+		}
+
+		nodes[i].parent = parentNode
+	}
+
+	rootsBySubject := make(map[string]*topicNode)
+
+	for i, message := range messages {
+		if nodes[i].parent != nil {
+			continue
+		}
+
+		subject := parse.NormalizeSubject(message.Title)
+		if subject == "" {
+			continue
+		}
+
+		if root, exists := rootsBySubject[subject]; exists && parse.IsReplySubject(message.Title) {
+			nodes[i].parent = root
+		} else if !exists {
+			rootsBySubject[subject] = nodes[i]
+		}
+	}
+
+	var roots []*topicNode
+
+	// Messages are sorted by date, so this keeps the replies to each message in
+	// the order they were sent.
+	for _, node := range nodes {
+		if node.parent == nil {
+			roots = append(roots, node)
+		} else {
+			node.parent.children = append(node.parent.children, node)
+		}
+	}
+
+	topics := make([]topic, len(roots))
+
+	for topicIndex, root := range roots {
+		topics[topicIndex] = topic{messages: root.flatten(messages, 0, nil)}
+
+		for position, message := range topics[topicIndex].messages {
+			args := &messages[message.Index-1]
+			args.TopicPermalink = topicPermalink(topicIndex + 1)
+
+			// The previous and next messages in the topic are in the order they
+			// appear on the topic page, so they follow each branch of the
+			// conversation to its end before moving on to the next.
+			if position > 0 {
+				prev := messageLink(topics[topicIndex].messages[position-1].MessageArgs)
+				args.PrevInTopic = &prev
+			}
+
+			if position < len(topics[topicIndex].messages)-1 {
+				next := messageLink(topics[topicIndex].messages[position+1].MessageArgs)
+				args.NextInTopic = &next
+			}
+		}
+	}
+
+	return topics
+}
+
+// refreshTopicMessages updates the copies of the messages in each topic, since
+// topics are grouped before the messages are split into pages.
+func refreshTopicMessages(topics []topic, messages []MessageArgs) {
+	for _, topic := range topics {
+		for i := range topic.messages {
+			topic.messages[i].MessageArgs = messages[topic.messages[i].Index-1]
+		}
+	}
+}
+
+func (t topic) summary(number int) TopicSummaryArgs {
+	starter := t.messages[0]
+
+	title := starter.Title
+	if title == "" {
+		title = untitledTopicTitle
+	}
+
+	var (
+		participants []string
+		seen         = make(map[string]struct{})
+		lastActivity = starter.MessageArgs
+	)
+
+	for _, message := range t.messages {
+		if _, isSeen := seen[message.User]; !isSeen {
+			seen[message.User] = struct{}{}
+			participants = append(participants, message.User)
+		}
+
+		if message.Index > lastActivity.Index {
+			lastActivity = message.MessageArgs
+		}
+	}
+
+	return TopicSummaryArgs{
+		Number:                number,
+		Permalink:             topicPermalink(number),
+		Title:                 title,
+		Starter:               starter.User,
+		ReplyCount:            len(t.messages) - 1,
+		Participants:          participants,
+		LastActivityTimestamp: lastActivity.Timestamp,
+		LastActivityDatetime:  lastActivity.FormattedDatetime,
+		lastActivityIndex:     lastActivity.Index,
+	}
+}
+
+func buildTopicArgs(topics []topic, messages []MessageArgs, config OutputConfig) (TopicIndexArgs, []TopicPageArgs) {
+	site := config.siteArgs()
+
+	summaries := make([]TopicSummaryArgs, len(topics))
+	pages := make([]TopicPageArgs, len(topics))
+
+	for topicIndex, topic := range topics {
+		summary := topic.summary(topicIndex + 1)
+		summaries[topicIndex] = summary
+
+		description := topic.messages[0].Summary
+		if description == "" {
+			description = site.Description
+		}
+
+		var structuredData []MessageData
+
+		if config.publishesStructuredData() {
+			structuredData = make([]MessageData, len(topic.messages))
+
+			for i, message := range topic.messages {
+				structuredData[i] = messageStructuredData(messages, message.MessageArgs, config)
+			}
+		}
+
+		pages[topicIndex] = TopicPageArgs{
+			SiteArgs: site,
+			Meta: PageMetaArgs{
+				Title:       fmt.Sprintf("%s - %s", summary.Title, site.Title),
+				Description: description,
+				Canonical:   canonicalUrl(config.BaseUrl, summary.Permalink),
+				Type:        "article",
+				Published:   topic.messages[0].Timestamp,
+				Feed:        pageFeedLink(config.Feeds.PerTopic, fmt.Sprintf("%s - %s", summary.Title, site.Title), summary.Permalink, config),
+			},
+			Topic:          summary,
+			Messages:       topic.messages,
+			StructuredData: structuredData,
+		}
+	}
+
+	// The most recently active topics are listed first in the index.
+	sortedSummaries := make([]TopicSummaryArgs, len(summaries))
+	copy(sortedSummaries, summaries)
+
+	sort.SliceStable(sortedSummaries, func(i, j int) bool {
+		return sortedSummaries[i].lastActivityIndex > sortedSummaries[j].lastActivityIndex
+	})
+
+	index := TopicIndexArgs{
+		SiteArgs: site,
+		Meta: PageMetaArgs{
+			Title:       fmt.Sprintf("Topics - %s", site.Title),
+			Description: site.Description,
+			Canonical:   canonicalUrl(config.BaseUrl, topicIndexPath()),
+			Type:        "website",
+		},
+		Topics: sortedSummaries,
+	}
+
+	return index, pages
+}
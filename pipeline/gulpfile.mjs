@@ -38,6 +38,9 @@ const cssSources = [
   "src/css/global.css",
   "src/css/components.css",
   "src/css/thread.css",
+  "src/css/topics.css",
+  "src/css/members.css",
+  "src/css/archive.css",
   "src/css/search.css",
 ];
 
@@ -163,9 +166,20 @@ async function headers() {
     .pipe(dest(publicDir));
 }
 
-function robots() {
+// The parser writes the absolute URL of the sitemap next to it, since
+// robots.txt needs to link to it and only the parser knows the base URL.
+const readSitemapUrl = async () => {
+  const sitemapUrl = await fs
+    .readFile(path.join(outputDir, "sitemap-url.txt"), "utf8")
+    .catch(() => undefined);
+
+  return sitemapUrl?.trim() || undefined;
+};
+
+async function robots() {
   if (disallowRobots === undefined) {
-    return src("src/allow.robots.txt")
+    return src("src/allow.robots.handlebars")
+      .pipe(handlebars({ sitemapUrl: await readSitemapUrl() }))
       .pipe(rename("robots.txt"))
       .pipe(dest(publicDir));
   } else {
@@ -186,6 +200,39 @@ function font() {
   ]).pipe(dest("font", { cwd: publicDir }));
 }
 
+function source() {
+  return src(path.join(outputDir, "source/*.eml"), { allowEmpty: true }).pipe(
+    dest("source", { cwd: publicDir })
+  );
+}
+
+function feeds() {
+  return src(
+    [path.join(outputDir, "**/feed.xml"), path.join(outputDir, "rss.xml")],
+    { allowEmpty: true }
+  ).pipe(dest(publicDir));
+}
+
+function sitemap() {
+  if (disallowRobots !== undefined) return Promise.resolve();
+
+  return src(path.join(outputDir, "sitemap*.xml"), { allowEmpty: true }).pipe(
+    dest(publicDir)
+  );
+}
+
+// The redirects generated from the manifest, for hosting providers which
+// support a `_redirects` file and for nginx.
+function redirects() {
+  return src(
+    [
+      path.join(outputDir, "_redirects"),
+      path.join(outputDir, "redirects.nginx.conf"),
+    ],
+    { allowEmpty: true }
+  ).pipe(dest(publicDir));
+}
+
 function cleanOutput() {
   return deleteAsync(outputDir, { force: true });
 }
@@ -214,7 +261,7 @@ export const clean = parallel(cleanOutput, cleanPublic);
 
 const main = series(
   cleanPublic,
-  parallel(html, font, robots, searchIndex),
+  parallel(html, font, robots, searchIndex, source, feeds, sitemap, redirects),
   headers,
   captureScreenshot,
   cleanOutput
@@ -0,0 +1,48 @@
+// Keyboard shortcuts for reading a topic one message at a time, since the
+// messages in a topic are often interleaved with other topics on the thread
+// pages. The search shortcuts are in `search.js`, since that's only included
+// when search is enabled.
+
+const topicNavKeys = {
+  p: "prev",
+  n: "next",
+};
+
+const isTyping = (target) =>
+  target instanceof HTMLInputElement ||
+  target instanceof HTMLTextAreaElement ||
+  target instanceof HTMLSelectElement ||
+  target?.isContentEditable;
+
+// The message the reader is on is the one linked to in the URL if it's on the
+// page, or else the first one which is still on the screen.
+const currentMessage = () => {
+  const linked = window.location.hash.match(/^#message-\d+$/)
+    ? document.getElementById(window.location.hash.slice(1))
+    : null;
+
+  if (linked !== null) return linked;
+
+  return (
+    Array.from(document.querySelectorAll(".message")).find(
+      (message) => message.getBoundingClientRect().bottom > 0
+    ) ?? null
+  );
+};
+
+const navigateTopic = (e) => {
+  if (e.altKey || e.ctrlKey || e.metaKey || isTyping(e.target)) return;
+
+  const direction = topicNavKeys[e.key];
+  if (direction === undefined) return;
+
+  const link = currentMessage()?.querySelector(
+    `[data-topic-nav="${direction}"]`
+  );
+  if (!link) return;
+
+  e.preventDefault();
+  link.click();
+};
+
+document.addEventListener("keydown", navigateTopic);
@@ -0,0 +1,23 @@
+// Links to a message on a thread page include the number of the message in
+// the fragment, like `/3/#message-57`. When the pages are split differently
+// than they were when the link was made, the message may no longer be on that
+// page, so we send the reader to the page for the message instead. The path of
+// that page comes from the `message-permalink` meta tag, where `{number}` is
+// replaced with the number of the message.
+const redirectToMessage = () => {
+  const match = window.location.hash.match(/^#message-(\d+)$/);
+  const permalink = document.querySelector('meta[name="message-permalink"]');
+
+  if (
+    match === null ||
+    permalink === null ||
+    document.getElementById(`message-${match[1]}`)
+  ) {
+    return;
+  }
+
+  window.location.replace(permalink.content.replace("{number}", match[1]));
+};
+
+redirectToMessage();
+window.addEventListener("hashchange", redirectToMessage);
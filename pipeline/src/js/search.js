@@ -45,8 +45,13 @@ const acceptSuggestion = (suggestions) => {
   return false;
 };
 
-const hrefForMessage = (id, page) => {
-  if (page === 1) {
+// Search indices built before messages had their own pages don't have a URL
+// for each message, so we fall back to linking to the message on its page of
+// the thread.
+const hrefForMessage = (id, page, url) => {
+  if (url !== undefined) {
+    return url;
+  } else if (page === 1) {
     return `/#message-${id}`;
   } else {
     return `/${page}/#message-${id}`;
@@ -82,7 +87,7 @@ const showResults = async (index, search, suggestions) => {
 
   results.forEach((result) => {
     result.result.forEach((r) => {
-      flatResults[hrefForMessage(r.doc.id.toString(), r.doc.page)] = r.doc;
+      flatResults[hrefForMessage(r.doc.id.toString(), r.doc.page, r.doc.url)] = r.doc;
     });
   });
 
@@ -193,7 +198,7 @@ const indexSearch = async (search, suggestions) => {
     preset: "memory",
     document: {
       id: "id",
-      store: ["id", "page", "timestamp", "user", "title", "summary"],
+      store: ["id", "page", "url", "timestamp", "user", "title", "summary"],
       index: ["user", "flair", "year", "title", "body"],
     },
   });